@@ -0,0 +1,39 @@
+// Command replay sends the requests recorded by a node started with -record
+// to another node, for reproducing production issues against a test cluster.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	pb "distributed-kv-store/kvstore"
+	"distributed-kv-store/server"
+
+	"google.golang.org/grpc"
+)
+
+func main() {
+	recording := flag.String("recording", "", "request recording written by a node started with -record")
+	target := flag.String("target", "localhost:50051", "address of the node to replay against")
+	flag.Parse()
+
+	file, err := os.Open(*recording)
+	if err != nil {
+		log.Fatalf("Failed to open recording: %v", err)
+	}
+	defer file.Close()
+
+	conn, err := grpc.Dial(*target, grpc.WithInsecure())
+	if err != nil {
+		log.Fatalf("Failed to connect to %s: %v", *target, err)
+	}
+	defer conn.Close()
+
+	sent, failed, err := server.Replay(context.Background(), file, pb.NewKeyValueServiceClient(conn))
+	log.Printf("Replayed %d requests, %d failed", sent, failed)
+	if err != nil {
+		log.Fatalf("Replay stopped: %v", err)
+	}
+}
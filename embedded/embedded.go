@@ -0,0 +1,176 @@
+// Package embedded runs the key-value store inside another Go program, with
+// no gRPC server and no network. It wires a store.KeyValueStore to a
+// single-node hash.HashRing, so keys are placed and expire exactly as on a
+// server node, but every key is owned locally and nothing is forwarded.
+// It suits tests and services that want the store as a library.
+package embedded
+
+import (
+	"sync"
+	"time"
+
+	"distributed-kv-store/hash"
+	"distributed-kv-store/store"
+)
+
+// Address is the name the embedded node has on its ring.
+const Address = "local"
+
+// Options configures an embedded store. The zero value is a plain in-memory
+// store; the fields mirror their server.Config counterparts.
+type Options struct {
+	VirtualNodes        int             // virtual nodes on the ring (default 3)
+	CapacityHint        int             // number of keys to size the store for up front; not a limit
+	MaxValueSize        int             // maximum value size in bytes (0 means unlimited)
+	AllowEmptyKeys      bool            // accept "" as a key instead of rejecting it with store.ErrEmptyKey
+	SoftDeleteRetention time.Duration   // keep deleted keys restorable with Undelete for this long (0 deletes immediately)
+	ColdStore           store.ColdStore // optional slower tier behind the in-memory store
+	SweepInterval       time.Duration   // how often expired keys are reclaimed (default one minute, negative disables)
+}
+
+// Store is a single-node key-value store running in-process.
+type Store struct {
+	kvs        *store.KeyValueStore
+	ring       *hash.HashRing
+	allowEmpty bool
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Open creates an embedded store. Close it to stop its background sweep and
+// release its cold tier.
+func Open(opts Options) *Store {
+	if opts.VirtualNodes < 1 {
+		opts.VirtualNodes = 3
+	}
+	if opts.SweepInterval == 0 {
+		opts.SweepInterval = time.Minute
+	}
+
+	kvs := store.NewKeyValueStoreWithCapacity(opts.CapacityHint)
+	kvs.SetMaxValueSize(opts.MaxValueSize)
+	if opts.ColdStore != nil {
+		kvs.SetColdStore(opts.ColdStore)
+	}
+	kvs.SetSoftDelete(opts.SoftDeleteRetention)
+
+	ring := hash.NewHashRing(opts.VirtualNodes)
+	ring.AddNode(Address)
+
+	s := &Store{kvs: kvs, ring: ring, allowEmpty: opts.AllowEmptyKeys, stop: make(chan struct{}), done: make(chan struct{})}
+	go s.sweep(opts.SweepInterval)
+	return s
+}
+
+// sweep reclaims expired keys every interval until the store is closed.
+func (s *Store) sweep(interval time.Duration) {
+	defer close(s.done)
+	if interval < 0 {
+		<-s.stop
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.kvs.DeleteExpired()
+		}
+	}
+}
+
+// owner returns the node responsible for key. An embedded ring only ever
+// holds this node, so it is where a server would have forwarded to. Empty
+// keys are rejected as a node rejects them.
+func (s *Store) owner(key string) (string, error) {
+	if key == "" && !s.allowEmpty {
+		return "", store.ErrEmptyKey
+	}
+	node := s.ring.GetNode(key)
+	if node == "" {
+		return "", hash.ErrRingEmpty
+	}
+	return node, nil
+}
+
+// Put inserts or updates a key-value pair, as the Put RPC does.
+func (s *Store) Put(key, value string) (uint64, error) {
+	return s.PutWithOptions(key, value, store.PutOptions{})
+}
+
+// PutWithTTL inserts or updates a key-value pair that expires after ttl.
+func (s *Store) PutWithTTL(key, value string, ttl time.Duration) (uint64, error) {
+	return s.PutWithOptions(key, value, store.PutOptions{TTL: ttl})
+}
+
+// PutWithOptions writes a key-value pair and returns its new version. A
+// conditional write that does not match fails with a
+// *store.VersionConflictError.
+func (s *Store) PutWithOptions(key, value string, opts store.PutOptions) (uint64, error) {
+	if _, err := s.owner(key); err != nil {
+		return 0, err
+	}
+	return s.kvs.PutWithOptions(key, value, opts)
+}
+
+// Get returns a key's value and whether it was found, as the Get RPC does.
+func (s *Store) Get(key string) (string, bool) {
+	e, found := s.GetWithMetadata(key)
+	return e.Value, found
+}
+
+// GetWithMetadata returns a key's value along with its version, expiry and
+// modification time.
+func (s *Store) GetWithMetadata(key string) (store.Entry, bool) {
+	if _, err := s.owner(key); err != nil {
+		return store.Entry{}, false
+	}
+	return s.kvs.GetWithMetadata(key)
+}
+
+// Delete removes a key. It returns store.ErrKeyNotFound if the key does not
+// exist.
+func (s *Store) Delete(key string) error {
+	if _, err := s.owner(key); err != nil {
+		return err
+	}
+	return s.kvs.Delete(key)
+}
+
+// Scan calls fn for every live key with the given prefix until fn returns
+// false.
+func (s *Store) Scan(prefix string, fn func(store.Entry) bool) {
+	s.kvs.Scan(prefix, fn)
+}
+
+// Len returns the number of live keys.
+func (s *Store) Len() int {
+	return s.kvs.Len()
+}
+
+// KeyValueStore returns the underlying store, for operations the facade
+// does not wrap.
+func (s *Store) KeyValueStore() *store.KeyValueStore {
+	return s.kvs
+}
+
+// Ring returns the store's single-node hash ring.
+func (s *Store) Ring() *hash.HashRing {
+	return s.ring
+}
+
+// Close stops the background sweep and closes the store. The store must not
+// be used afterwards.
+func (s *Store) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		err = s.kvs.Close()
+	})
+	return err
+}
@@ -3,6 +3,7 @@ module distributed-kv-store
 go 1.22.2
 
 require (
+	go.uber.org/goleak v1.3.0
 	google.golang.org/grpc v1.69.0
 	google.golang.org/protobuf v1.35.1
 )
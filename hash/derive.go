@@ -0,0 +1,18 @@
+package hash
+
+import "crypto/sha256"
+
+// KeyDerivation maps a key to the placement key that is hashed onto the ring.
+// It only affects where a key lives; the key itself is stored unchanged.
+type KeyDerivation func(key string) string
+
+// SaltedKeys returns a KeyDerivation that runs keys through SHA-256 with a
+// salt before placement. crc32 alone keeps sequential keys such as
+// monotonically increasing IDs close together; the extra round scatters them
+// evenly around the ring.
+func SaltedKeys(salt string) KeyDerivation {
+	return func(key string) string {
+		sum := sha256.Sum256([]byte(salt + key))
+		return string(sum[:])
+	}
+}
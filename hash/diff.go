@@ -0,0 +1,76 @@
+package hash
+
+import "sort"
+
+// Transfer is a range of the ring whose owner changed between two layouts.
+// From is "" if the range had no owner before (the ring was empty), To is ""
+// if it has none after.
+type Transfer struct {
+	Range Range  `json:"range"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Diff returns the ranges of the ring that changed owner between two
+// layouts, in ring order, merging adjacent ranges with the same endpoints.
+// Only keys in these ranges move; everything else keeps its owner.
+//
+// Virtual node positions depend only on a node's address (apart from the
+// rare collision), so adding a node moves exactly the arcs its new virtual
+// nodes now cover, each from the node that owned it, and removing a node
+// hands each of its arcs to the next virtual node clockwise. A rebalancer
+// can transfer just these ranges instead of rescanning every key.
+func Diff(before, after Layout) []Transfer {
+	// Every boundary from either layout; between consecutive boundaries
+	// ownership is constant on both sides.
+	seen := make(map[uint32]bool)
+	var points []uint32
+	for _, layout := range []Layout{before, after} {
+		for _, vn := range layout.VirtualNodes {
+			if !seen[vn.Hash] {
+				seen[vn.Hash] = true
+				points = append(points, vn.Hash)
+			}
+		}
+	}
+	if len(points) == 0 {
+		return nil
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i] < points[j] })
+
+	var transfers []Transfer
+	for i, end := range points {
+		start := points[(i+len(points)-1)%len(points)]
+		from, to := ownerAt(before, end), ownerAt(after, end)
+		if from == to {
+			continue
+		}
+		if n := len(transfers); n > 0 && transfers[n-1].Range.End == start &&
+			transfers[n-1].From == from && transfers[n-1].To == to {
+			transfers[n-1].Range.End = end
+			continue
+		}
+		transfers = append(transfers, Transfer{Range: Range{Start: start, End: end}, From: from, To: to})
+	}
+	// The last transfer may continue into the first across the top of the ring.
+	if n := len(transfers); n > 1 && transfers[n-1].Range.End == transfers[0].Range.Start &&
+		transfers[n-1].From == transfers[0].From && transfers[n-1].To == transfers[0].To {
+		transfers[0].Range.Start = transfers[n-1].Range.Start
+		transfers = transfers[:n-1]
+	}
+	return transfers
+}
+
+// ownerAt returns the node owning ring position pos in layout, or "" if the
+// layout is empty. VirtualNodes are sorted by hash.
+func ownerAt(layout Layout, pos uint32) string {
+	vnodes := layout.VirtualNodes
+	if len(vnodes) == 0 {
+		return ""
+	}
+	i := sort.Search(len(vnodes), func(i int) bool { return vnodes[i].Hash >= pos })
+	if i == len(vnodes) {
+		i = 0
+	}
+	return vnodes[i].Node
+}
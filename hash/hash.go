@@ -1,39 +1,311 @@
 package hash
 
-import(
+import (
+	"errors"
 	"hash/crc32"
+	"slices"
 	"sort"
 	"strconv"
+	"sync"
 )
 
+// ErrRingEmpty is returned when a key cannot be placed because the ring has no nodes.
+var ErrRingEmpty = errors.New("hash ring is empty")
+
+// Metadata holds descriptive attributes of a node, such as "zone", "rack"
+// or "capacity". It does not affect GetNode.
+type Metadata map[string]string
+
+// maxRehash bounds how many times a colliding virtual node is re-hashed.
+const maxRehash = 64
+
 type HashRing struct {
-	nodes       []int 
-	nodeMap    map[int]string
-	replication int
+	mu          sync.RWMutex
+	nodes       []uint32 // virtual node positions, sorted
+	nodeMap     map[uint32]string
+	members     map[string]bool
+	metadata    map[string]Metadata
+	replication int           // virtual nodes per physical node
+	maxVirtual  int           // cap on virtual nodes across the ring; 0 means no cap
+	epoch       uint64        // bumped on every membership change
+	changed     chan struct{} // closed and replaced whenever epoch is bumped
+	derive      KeyDerivation // nil places keys by their own bytes
+	hash        HashFunc      // nil uses crc32
 }
 
-//NewHashRing creates a new hash ring
+// HashFunc maps bytes to a ring position.
+type HashFunc func(data []byte) uint32
+
+// NewHashRing creates a new hash ring
 func NewHashRing(replication int) *HashRing {
+	if replication < 1 {
+		replication = 1
+	}
 	return &HashRing{
-		nodes :     []int{},
-		nodeMap:    make(map[int]string),
+		nodes:       []uint32{},
+		nodeMap:     make(map[uint32]string),
+		members:     make(map[string]bool),
+		metadata:    make(map[string]Metadata),
 		replication: replication,
+		changed:     make(chan struct{}),
 	}
 }
 
-//AddNode adds a node to the hash ring
+// AddNode adds a node to the hash ring
 func (hr *HashRing) AddNode(node string) {
-	for i:=0; i< hr.replication; i++ {
-		hash:= int(crc32.ChecksumIEEE([]byte(node+strconv.Itoa(i))))
-		hr.nodes = append(hr.nodes, hash)
-		hr.nodeMap[hash] = node
-	}	
-	sort.Ints(hr.nodes)
+	hr.AddNodeWithMetadata(node, nil)
+}
+
+// AddNodeWithMetadata adds a node to the hash ring and records its metadata.
+// The empty address is ignored, since GetNode uses "" to mean "no node".
+// Adding a node that is already in the ring leaves the ring and its epoch
+// untouched, so membership events delivered more than once are harmless;
+// non-empty metadata still replaces what was recorded for it.
+func (hr *HashRing) AddNodeWithMetadata(node string, meta Metadata) {
+	if node == "" {
+		return
+	}
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	if len(meta) > 0 {
+		hr.metadata[node] = copyMetadata(meta)
+	}
+	if hr.members[node] {
+		return
+	}
+	hr.members[node] = true
+	hr.rebuildLocked()
+	hr.bumpEpochLocked()
+}
+
+// HasNode reports whether a node is in the ring.
+func (hr *HashRing) HasNode(node string) bool {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	return hr.members[node]
 }
 
-//GetNode returns the node for a given key
+// RemoveNode removes a node and all of its virtual nodes from the hash ring.
+// Removing a node that is not in the ring does nothing.
+func (hr *HashRing) RemoveNode(node string) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	if !hr.members[node] {
+		return
+	}
+	delete(hr.members, node)
+	delete(hr.metadata, node)
+	hr.rebuildLocked()
+	hr.bumpEpochLocked()
+}
+
+// rebuildLocked places every member's virtual nodes on the ring. Members are
+// placed in address order and a virtual node whose position is already taken
+// is re-hashed until it finds a free one, so no node can shadow another and
+// every ring with the same members ends up with the same layout regardless
+// of the order they were added in. A virtual node that is still colliding
+// after maxRehash attempts, which only a degenerate HashFunc can cause, is
+// left out rather than looping forever.
+func (hr *HashRing) rebuildLocked() {
+	members := make([]string, 0, len(hr.members))
+	for node := range hr.members {
+		members = append(members, node)
+	}
+	sort.Strings(members)
+
+	perNode := hr.virtualNodesLocked()
+	hr.nodes = hr.nodes[:0]
+	hr.nodeMap = make(map[uint32]string, len(members)*perNode)
+	for _, node := range members {
+		for i := 0; i < perNode; i++ {
+			hash := hr.virtualNodeHash(node, i, 0)
+			_, taken := hr.nodeMap[hash]
+			for round := 1; taken && round <= maxRehash; round++ {
+				hash = hr.virtualNodeHash(node, i, round)
+				_, taken = hr.nodeMap[hash]
+			}
+			if taken {
+				continue
+			}
+			hr.nodes = append(hr.nodes, hash)
+			hr.nodeMap[hash] = node
+		}
+	}
+	sort.Slice(hr.nodes, func(i, j int) bool { return hr.nodes[i] < hr.nodes[j] })
+}
+
+// virtualNodesLocked returns how many virtual nodes each member gets: the
+// configured count, scaled down so the ring stays within its cap. Every
+// member keeps at least one, so a ring with more members than the cap
+// exceeds it.
+func (hr *HashRing) virtualNodesLocked() int {
+	perNode := hr.replication
+	if hr.maxVirtual > 0 && len(hr.members) > 0 && perNode*len(hr.members) > hr.maxVirtual {
+		perNode = max(1, hr.maxVirtual/len(hr.members))
+	}
+	return perNode
+}
+
+// SetMaxVirtualNodes caps the total number of virtual nodes on the ring,
+// which bounds its memory and lookup cost in large clusters. When the
+// members would exceed it, every member's virtual node count is scaled
+// down equally, so their relative weights are kept. Changing the cap
+// re-places the members. 0 removes the cap. Every node in a cluster must
+// use the same cap.
+func (hr *HashRing) SetMaxVirtualNodes(max int) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	hr.maxVirtual = max
+	hr.rebuildLocked()
+	hr.bumpEpochLocked()
+}
+
+// virtualNodeHash returns the ring position of a node's i-th virtual node.
+// The index is separated from the address so that, for example, "node1" #11
+// and "node11" #1 hash differently, and the crc32 is passed through a mixing
+// round because crc32 of near-identical short strings is poorly spread.
+// round is bumped to re-hash a position that is already taken. An injected
+// HashFunc is used as is, so it fully controls where virtual nodes land.
+func (hr *HashRing) virtualNodeHash(node string, i, round int) uint32 {
+	key := node + "#" + strconv.Itoa(i)
+	if round > 0 {
+		key += "#" + strconv.Itoa(round)
+	}
+	if hr.hash != nil {
+		return hr.hash([]byte(key))
+	}
+	return mix32(crc32.ChecksumIEEE([]byte(key)))
+}
+
+// mix32 is the murmur3 finalizer: a bijection on uint32 that spreads small
+// input differences across every output bit.
+func mix32(h uint32) uint32 {
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+// Nodes returns the physical nodes in the ring, sorted by address
+func (hr *HashRing) Nodes() []string {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	nodes := make([]string, 0, len(hr.members))
+	for node := range hr.members {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Metadata returns a copy of the metadata recorded for a node, or nil if there is none
+func (hr *HashRing) Metadata(node string) Metadata {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	return copyMetadata(hr.metadata[node])
+}
+
+func copyMetadata(meta Metadata) Metadata {
+	if meta == nil {
+		return nil
+	}
+	c := make(Metadata, len(meta))
+	for k, v := range meta {
+		c[k] = v
+	}
+	return c
+}
+
+// Epoch returns a counter that changes every time a node is added or removed
+func (hr *HashRing) Epoch() uint64 {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	return hr.epoch
+}
+
+// RestoreEpoch raises the epoch to at least epoch, so a restarted node
+// carries on counting from the epoch it last saw instead of from zero. It
+// never lowers the epoch.
+func (hr *HashRing) RestoreEpoch(epoch uint64) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	if epoch > hr.epoch {
+		hr.epoch = epoch - 1
+		hr.bumpEpochLocked()
+	}
+}
+
+// Changed returns a channel that is closed the next time the ring changes.
+// Read the ring after calling Changed, not before, so no change is missed.
+func (hr *HashRing) Changed() <-chan struct{} {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	return hr.changed
+}
+
+// bumpEpochLocked records a change to the ring and wakes everyone waiting on Changed.
+func (hr *HashRing) bumpEpochLocked() {
+	hr.epoch++
+	close(hr.changed)
+	hr.changed = make(chan struct{})
+}
+
+// SetKeyDerivation changes how keys are turned into ring positions. Every
+// node in the cluster must use the same derivation or they will disagree on
+// placement.
+func (hr *HashRing) SetKeyDerivation(derive KeyDerivation) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	hr.derive = derive
+}
+
+// SetHashFunc replaces crc32 as the hash placing both keys and virtual nodes
+// on the ring, and re-places the current members. It exists mainly so tests
+// can pin placement exactly, see StaticHash. Every node in a cluster must use
+// the same function.
+func (hr *HashRing) SetHashFunc(hash HashFunc) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	hr.hash = hash
+	hr.rebuildLocked()
+	hr.bumpEpochLocked()
+}
+
+// keyBufs holds buffers keyHash copies keys into, so hashing a key does not
+// allocate. Keys longer than maxPooledKey are converted directly instead, so
+// the pool never pins large buffers.
+var keyBufs = sync.Pool{New: func() any { return new([]byte) }}
+
+const maxPooledKey = 1024
+
+// keyHash returns the ring position of a key.
+func (hr *HashRing) keyHash(key string) uint32 {
+	if hr.derive != nil {
+		key = hr.derive(key)
+	}
+	if hr.hash != nil {
+		return hr.hash([]byte(key))
+	}
+	if len(key) > maxPooledKey {
+		return crc32.ChecksumIEEE([]byte(key))
+	}
+	buf := keyBufs.Get().(*[]byte)
+	*buf = append((*buf)[:0], key...)
+	sum := crc32.ChecksumIEEE(*buf)
+	keyBufs.Put(buf)
+	return sum
+}
+
+// GetNode returns the node for a given key, or "" if the ring is empty
 func (hr *HashRing) GetNode(key string) string {
-	hash := int(crc32.ChecksumIEEE([]byte(key)))
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	if len(hr.nodes) == 0 {
+		return ""
+	}
+	hash := hr.keyHash(key)
 	idx := sort.Search(len(hr.nodes), func(i int) bool {
 		return hr.nodes[i] >= hash
 	})
@@ -42,3 +314,27 @@ func (hr *HashRing) GetNode(key string) string {
 	}
 	return hr.nodeMap[hr.nodes[idx]]
 }
+
+// GetNodes returns up to n distinct nodes for a given key, walking clockwise
+// from the key's position. The first entry is the same node GetNode returns.
+func (hr *HashRing) GetNodes(key string, n int) []string {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	if len(hr.nodes) == 0 || n < 1 {
+		return nil
+	}
+	hash := hr.keyHash(key)
+	idx := sort.Search(len(hr.nodes), func(i int) bool {
+		return hr.nodes[i] >= hash
+	})
+
+	// n is small, so a linear scan of the result beats a map of seen nodes.
+	result := make([]string, 0, min(n, len(hr.members)))
+	for i := 0; i < len(hr.nodes) && len(result) < n; i++ {
+		node := hr.nodeMap[hr.nodes[(idx+i)%len(hr.nodes)]]
+		if !slices.Contains(result, node) {
+			result = append(result, node)
+		}
+	}
+	return result
+}
@@ -0,0 +1,311 @@
+package hash
+
+import (
+	"fmt"
+	"hash/crc32"
+	"maps"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// staticRing returns a ring with one virtual node per member, placed by
+// StaticHash: a at 100, b at 200 and c at 300.
+func staticRing(t *testing.T, keys map[string]uint32) *HashRing {
+	t.Helper()
+	positions := map[string]uint32{"a#0": 100, "b#0": 200, "c#0": 300}
+	for key, pos := range keys {
+		positions[key] = pos
+	}
+	ring := NewHashRing(1)
+	ring.SetHashFunc(StaticHash(positions))
+	for _, node := range []string{"a", "b", "c"} {
+		ring.AddNode(node)
+	}
+	return ring
+}
+
+func TestGetNodePlacement(t *testing.T) {
+	ring := staticRing(t, map[string]uint32{
+		"zero":   0,
+		"below":  99,
+		"exactA": 100,
+		"afterA": 101,
+		"exactB": 200,
+		"exactC": 300,
+		"past":   301,
+		"top":    ^uint32(0),
+	})
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"zero", "a"},
+		{"below", "a"},
+		{"exactA", "a"}, // an exact match belongs to that virtual node
+		{"afterA", "b"},
+		{"exactB", "b"},
+		{"exactC", "c"},
+		{"past", "a"}, // past the last virtual node wraps to the first
+		{"top", "a"},
+	}
+	for _, tt := range tests {
+		if got := ring.GetNode(tt.key); got != tt.want {
+			t.Errorf("GetNode(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+		if got := ring.GetNodes(tt.key, 1); len(got) != 1 || got[0] != tt.want {
+			t.Errorf("GetNodes(%q, 1) = %v, want [%s]", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestGetNodesWrapsAround(t *testing.T) {
+	ring := staticRing(t, map[string]uint32{"k": 250, "past": 301})
+	tests := []struct {
+		key  string
+		n    int
+		want []string
+	}{
+		{"k", 2, []string{"c", "a"}},
+		{"k", 3, []string{"c", "a", "b"}},
+		{"k", 5, []string{"c", "a", "b"}}, // never more than the members
+		{"past", 2, []string{"a", "b"}},
+		{"k", 0, nil},
+	}
+	for _, tt := range tests {
+		if got := ring.GetNodes(tt.key, tt.n); !slices.Equal(got, tt.want) {
+			t.Errorf("GetNodes(%q, %d) = %v, want %v", tt.key, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestSingleNodeRing(t *testing.T) {
+	ring := NewHashRing(3)
+	ring.AddNode("only")
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if got := ring.GetNode(key); got != "only" {
+			t.Fatalf("GetNode(%q) = %q, want only", key, got)
+		}
+		if got := ring.GetNodes(key, 3); !slices.Equal(got, []string{"only"}) {
+			t.Fatalf("GetNodes(%q, 3) = %v, want [only]", key, got)
+		}
+	}
+}
+
+func TestEmptyRing(t *testing.T) {
+	ring := NewHashRing(3)
+	if got := ring.GetNode("k"); got != "" {
+		t.Errorf("GetNode on an empty ring = %q, want \"\"", got)
+	}
+	if got := ring.GetNodes("k", 2); got != nil {
+		t.Errorf("GetNodes on an empty ring = %v, want nil", got)
+	}
+	ring.AddNode("a")
+	ring.RemoveNode("a")
+	if got := ring.GetNode("k"); got != "" {
+		t.Errorf("GetNode after removing the last node = %q, want \"\"", got)
+	}
+}
+
+func TestPositionsStaySorted(t *testing.T) {
+	ring := NewHashRing(50)
+	check := func(step string) {
+		t.Helper()
+		ring.mu.RLock()
+		defer ring.mu.RUnlock()
+		if !slices.IsSorted(ring.nodes) {
+			t.Fatalf("positions not sorted after %s", step)
+		}
+		if len(ring.nodeMap) != len(ring.nodes) {
+			t.Fatalf("after %s: %d positions but %d mapped", step, len(ring.nodes), len(ring.nodeMap))
+		}
+	}
+	for i := 0; i < 10; i++ {
+		ring.AddNode(fmt.Sprintf("node%d", i))
+		check(fmt.Sprintf("adding node%d", i))
+	}
+	for i := 0; i < 10; i += 3 {
+		ring.RemoveNode(fmt.Sprintf("node%d", i))
+		check(fmt.Sprintf("removing node%d", i))
+	}
+	ring.SetMaxVirtualNodes(64)
+	check("capping virtual nodes")
+	ring.SetHashFunc(StaticHash(nil))
+	check("replacing the hash")
+}
+
+func TestCollidingVirtualNodesAreRehashed(t *testing.T) {
+	// Both first virtual nodes want position 100; the later member in
+	// address order is moved, so every member keeps a virtual node.
+	ring := NewHashRing(1)
+	ring.SetHashFunc(StaticHash(map[string]uint32{"a#0": 100, "b#0": 100, "b#0#1": 500}))
+	ring.AddNode("b")
+	ring.AddNode("a")
+	if got := ring.GetNode("a#0"); got != "a" {
+		t.Errorf("position 100 owned by %q, want a", got)
+	}
+	if got := ring.GetNodes("a#0", 2); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("GetNodes = %v, want [a b]", got)
+	}
+}
+
+// TestManyNodesWithCollidingHash places dozens of nodes with a hash that has
+// only 251 positions, so many virtual nodes collide and must be re-hashed.
+func TestManyNodesWithCollidingHash(t *testing.T) {
+	const perNode = 3
+	narrow := func(data []byte) uint32 { return crc32.ChecksumIEEE(data) % 251 }
+	members := make([]string, 48)
+	for i := range members {
+		members[i] = fmt.Sprintf("10.0.%d.%d:50051", i/8, i%8)
+	}
+	build := func(order []string) *HashRing {
+		ring := NewHashRing(perNode)
+		ring.SetHashFunc(narrow)
+		for _, node := range order {
+			ring.AddNode(node)
+		}
+		return ring
+	}
+	ring := build(members)
+
+	owned := make(map[string]int)
+	for _, node := range ring.nodeMap {
+		owned[node]++
+	}
+	if len(ring.nodeMap) != len(ring.nodes) {
+		t.Fatalf("%d positions for %d virtual nodes", len(ring.nodeMap), len(ring.nodes))
+	}
+	for _, node := range members {
+		if owned[node] != perNode {
+			t.Errorf("%s owns %d virtual nodes, want %d", node, owned[node], perNode)
+		}
+	}
+	// Walking the ring from any key reaches every member, so none is shadowed.
+	if got := ring.GetNodes("key", len(members)); len(got) != len(members) {
+		t.Errorf("GetNodes reached %d of %d members", len(got), len(members))
+	}
+
+	reversed := slices.Clone(members)
+	slices.Reverse(reversed)
+	shuffled := slices.Clone(members)
+	rand.New(rand.NewSource(1)).Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	for name, other := range map[string]*HashRing{"reversed": build(reversed), "shuffled": build(shuffled)} {
+		if !maps.Equal(other.nodeMap, ring.nodeMap) {
+			t.Errorf("members added %s are placed differently", name)
+		}
+	}
+}
+
+// FuzzGetNode checks lookups on a fuzzed ring: prefix and count name its
+// members, and the member at drop is removed and added back, which must give
+// back the same placement for every key.
+func FuzzGetNode(f *testing.F) {
+	f.Add("", 1, "node", uint8(4), uint8(0))
+	f.Add("k", 3, "node", uint8(4), uint8(2))
+	f.Add(strings.Repeat("x", 1<<16), 2, "10.0.0.", uint8(9), uint8(8))
+	f.Add(strings.Repeat("\xff", 4096), 5, "", uint8(1), uint8(0))
+	f.Add("k", 8, "n#", uint8(15), uint8(7))
+	// Keys that StaticHash places on the boundary ring below at the bottom
+	// and top of the keyspace, exactly on its virtual nodes.
+	f.Add("lo#0", 2, "node", uint8(2), uint8(1))
+	f.Add("hi#0", 2, "node", uint8(2), uint8(1))
+
+	boundary := NewHashRing(1)
+	boundary.SetHashFunc(StaticHash(map[string]uint32{"lo#0": 0, "hi#0": ^uint32(0)}))
+	boundary.AddNode("lo")
+	boundary.AddNode("hi")
+
+	f.Fuzz(func(t *testing.T, key string, n int, prefix string, count, drop uint8) {
+		n = n%8 + 1
+		if n < 1 {
+			n += 8
+		}
+		members := make([]string, count%16+1)
+		for i := range members {
+			members[i] = prefix + strconv.Itoa(i)
+		}
+		crc := NewHashRing(20)
+		for _, node := range members {
+			crc.AddNode(node)
+		}
+		// The same members added in the opposite order must place keys alike.
+		reversed := NewHashRing(20)
+		for i := len(members) - 1; i >= 0; i-- {
+			reversed.AddNode(members[i])
+		}
+		node, nodes := crc.GetNode(key), crc.GetNodes(key, n)
+		if got := reversed.GetNodes(key, n); !slices.Equal(got, nodes) {
+			t.Fatalf("GetNodes(%q, %d) = %v, or %v with members added in reverse", key, n, nodes, got)
+		}
+
+		// Removing a member and adding it back restores the placement.
+		removed := members[int(drop)%len(members)]
+		crc.RemoveNode(removed)
+		crc.AddNode(removed)
+		if again := crc.GetNode(key); again != node {
+			t.Fatalf("GetNode(%q) = %q, then %q after removing and re-adding %q", key, node, again, removed)
+		}
+		if again := crc.GetNodes(key, n); !slices.Equal(again, nodes) {
+			t.Fatalf("GetNodes(%q, %d) = %v, then %v after removing and re-adding %q", key, n, nodes, again, removed)
+		}
+
+		for _, ring := range []*HashRing{crc, boundary} {
+			node := ring.GetNode(key)
+			if !ring.HasNode(node) {
+				t.Fatalf("GetNode(%q) = %q, not a member", key, node)
+			}
+			if again := ring.GetNode(key); again != node {
+				t.Fatalf("GetNode(%q) = %q then %q", key, node, again)
+			}
+			nodes := ring.GetNodes(key, n)
+			if want := min(n, len(ring.Nodes())); len(nodes) != want {
+				t.Fatalf("GetNodes(%q, %d) returned %d nodes, want %d", key, n, len(nodes), want)
+			}
+			if nodes[0] != node {
+				t.Fatalf("GetNodes(%q, %d)[0] = %q, GetNode = %q", key, n, nodes[0], node)
+			}
+			seen := make(map[string]bool, len(nodes))
+			for _, got := range nodes {
+				if seen[got] {
+					t.Fatalf("GetNodes(%q, %d) = %v, repeats %q", key, n, nodes, got)
+				}
+				seen[got] = true
+			}
+		}
+	})
+}
+
+// benchRing returns a five-node ring with 100 virtual nodes each and the
+// keys benchmarks look up on it.
+func benchRing() (*HashRing, []string) {
+	ring := NewHashRing(100)
+	for i := 0; i < 5; i++ {
+		ring.AddNode(fmt.Sprintf("10.0.0.%d:50051", i))
+	}
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("user:%d:profile", i)
+	}
+	return ring, keys
+}
+
+func BenchmarkGetNode(b *testing.B) {
+	ring, keys := benchRing()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ring.GetNode(keys[i%len(keys)])
+	}
+}
+
+func BenchmarkGetNodes(b *testing.B) {
+	ring, keys := benchRing()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ring.GetNodes(keys[i%len(keys)], 3)
+	}
+}
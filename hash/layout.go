@@ -0,0 +1,100 @@
+package hash
+
+// keyspace is the size of the crc32 hash space the ring is laid out on.
+const keyspace = 1 << 32
+
+// Layout is a point-in-time description of the ring, suitable for JSON export.
+type Layout struct {
+	Epoch           uint64                `json:"epoch"`
+	VirtualNodes    []VirtualNode         `json:"virtual_nodes"`
+	Nodes           map[string]NodeLayout `json:"nodes"`
+	PerNode         int                   `json:"virtual_nodes_per_node"`      // configured virtual nodes per node
+	MaxVirtualNodes int                   `json:"max_virtual_nodes,omitempty"` // cap on the total, see SetMaxVirtualNodes
+	Balance         *Balance              `json:"balance,omitempty"`           // nil if the ring is empty
+}
+
+// Balance summarizes how evenly the keyspace is divided between physical
+// nodes. It is computed from virtual node positions alone, so it describes
+// the ring before any keys are written.
+type Balance struct {
+	IdealShare float64 `json:"ideal_share"` // 1 / number of nodes
+	MinShare   float64 `json:"min_share"`
+	MaxShare   float64 `json:"max_share"`
+	Imbalance  float64 `json:"imbalance"` // MaxShare / IdealShare; 1 is perfectly even
+}
+
+// VirtualNode is one point on the ring. It owns the keys hashing into
+// (previous virtual node's hash, Hash], wrapping around at the top of the keyspace.
+type VirtualNode struct {
+	Hash uint32 `json:"hash"`
+	Node string `json:"node"`
+	Span uint64 `json:"span"` // number of hash values owned
+}
+
+// NodeLayout summarizes the virtual nodes of one physical node.
+type NodeLayout struct {
+	VirtualNodes int      `json:"virtual_nodes"` // effective count, after any cap
+	Span         uint64   `json:"span"`          // number of hash values owned across all virtual nodes
+	Share        float64  `json:"share"`         // Span as a fraction of the keyspace
+	Metadata     Metadata `json:"metadata,omitempty"`
+}
+
+// Layout returns a consistent snapshot of every virtual node and the share of
+// the keyspace owned by each physical node.
+func (hr *HashRing) Layout() Layout {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+
+	layout := Layout{
+		Epoch:           hr.epoch,
+		VirtualNodes:    make([]VirtualNode, 0, len(hr.nodes)),
+		Nodes:           make(map[string]NodeLayout),
+		PerNode:         hr.replication,
+		MaxVirtualNodes: hr.maxVirtual,
+	}
+	for i, hash := range hr.nodes {
+		var span uint64
+		if i == 0 {
+			// The first virtual node also owns everything above the last one.
+			span = uint64(hash) + keyspace - uint64(hr.nodes[len(hr.nodes)-1])
+		} else {
+			span = uint64(hash) - uint64(hr.nodes[i-1])
+		}
+		node := hr.nodeMap[hash]
+		layout.VirtualNodes = append(layout.VirtualNodes, VirtualNode{Hash: hash, Node: node, Span: span})
+
+		summary := layout.Nodes[node]
+		summary.VirtualNodes++
+		summary.Span += span
+		layout.Nodes[node] = summary
+	}
+	for node, summary := range layout.Nodes {
+		summary.Share = float64(summary.Span) / keyspace
+		summary.Metadata = copyMetadata(hr.metadata[node])
+		layout.Nodes[node] = summary
+
+		if b := layout.Balance; b == nil {
+			layout.Balance = &Balance{MinShare: summary.Share, MaxShare: summary.Share}
+		} else {
+			b.MinShare = min(b.MinShare, summary.Share)
+			b.MaxShare = max(b.MaxShare, summary.Share)
+		}
+	}
+	if b := layout.Balance; b != nil {
+		b.IdealShare = 1 / float64(len(layout.Nodes))
+		b.Imbalance = b.MaxShare / b.IdealShare
+	}
+	return layout
+}
+
+// Ownership returns the percentage of the keyspace each physical node owns,
+// summed over the arcs its virtual nodes cover. The percentages add up to
+// 100 for a non-empty ring.
+func (hr *HashRing) Ownership() map[string]float64 {
+	nodes := hr.Layout().Nodes
+	ownership := make(map[string]float64, len(nodes))
+	for node, summary := range nodes {
+		ownership[node] = summary.Share * 100
+	}
+	return ownership
+}
@@ -0,0 +1,61 @@
+package hash
+
+// Range is an arc of the ring: the positions in (Start, End], wrapping past
+// the top of the keyspace when Start >= End. Start == End covers the whole
+// ring. This matches how virtual nodes own keys, so the arcs a node owns are
+// Ranges too.
+type Range struct {
+	Start uint32 `json:"start"`
+	End   uint32 `json:"end"`
+}
+
+// Contains reports whether pos falls in the range.
+func (r Range) Contains(pos uint32) bool {
+	switch {
+	case r.Start == r.End:
+		return true
+	case r.Start < r.End:
+		return pos > r.Start && pos <= r.End
+	default:
+		return pos > r.Start || pos <= r.End
+	}
+}
+
+// SplitRing divides the ring into n equal, contiguous ranges. Every position
+// falls in exactly one of them, so they can be scanned or exported
+// concurrently without gaps or overlaps.
+func SplitRing(n int) []Range {
+	if n <= 1 {
+		return []Range{{}}
+	}
+	ranges := make([]Range, n)
+	for i := range ranges {
+		ranges[i] = Range{
+			Start: uint32(uint64(i) * keyspace / uint64(n)),
+			End:   uint32(uint64(i+1) * keyspace / uint64(n)), // wraps to 0 for the last range
+		}
+	}
+	return ranges
+}
+
+// Position returns the ring position a key is placed at.
+func (hr *HashRing) Position(key string) uint32 {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	return hr.keyHash(key)
+}
+
+// Ranges returns the arcs of the ring owned by node's virtual nodes, in ring order.
+func (hr *HashRing) Ranges(node string) []Range {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	var ranges []Range
+	for i, hash := range hr.nodes {
+		if hr.nodeMap[hash] != node {
+			continue
+		}
+		prev := hr.nodes[(i+len(hr.nodes)-1)%len(hr.nodes)]
+		ranges = append(ranges, Range{Start: prev, End: hash})
+	}
+	return ranges
+}
@@ -0,0 +1,23 @@
+package hash
+
+import "hash/crc32"
+
+// StaticHash returns a HashFunc for tests that places each string in
+// positions at the given ring position and hashes anything else with crc32.
+// Virtual nodes are hashed as "<node>#<index>", so
+//
+//	ring := NewHashRing(1)
+//	ring.SetHashFunc(StaticHash(map[string]uint32{
+//		"a#0": 100, "b#0": 200, // one virtual node each
+//		"k1": 150, // lands on b
+//	}))
+//
+// gives a ring whose placement can be asserted exactly.
+func StaticHash(positions map[string]uint32) HashFunc {
+	return func(data []byte) uint32 {
+		if pos, ok := positions[string(data)]; ok {
+			return pos
+		}
+		return crc32.ChecksumIEEE(data)
+	}
+}
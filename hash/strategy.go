@@ -0,0 +1,51 @@
+package hash
+
+// ReplicaStrategy chooses the ordered replica set for a key: the primary
+// first, followed by up to n-1 further distinct nodes.
+type ReplicaStrategy interface {
+	Replicas(ring *HashRing, key string, n int) []string
+}
+
+// NextN places replicas on the next n distinct nodes clockwise from the key.
+// It is the default strategy.
+type NextN struct{}
+
+func (NextN) Replicas(ring *HashRing, key string, n int) []string {
+	return ring.GetNodes(key, n)
+}
+
+// ZoneAware walks the ring like NextN but skips nodes whose "zone" metadata
+// matches a replica already chosen, so replicas land in distinct zones. If
+// there are fewer zones than n, the remaining replicas are filled in ring
+// order from zones already used.
+type ZoneAware struct{}
+
+func (ZoneAware) Replicas(ring *HashRing, key string, n int) []string {
+	candidates := ring.GetNodes(key, len(ring.Nodes()))
+	chosen := []string{}
+	picked := make(map[string]bool)
+	zones := make(map[string]bool)
+	for _, node := range candidates {
+		if len(chosen) == n {
+			return chosen
+		}
+		zone := ring.Metadata(node)["zone"]
+		if zone != "" && zones[zone] {
+			continue
+		}
+		if zone != "" {
+			zones[zone] = true
+		}
+		picked[node] = true
+		chosen = append(chosen, node)
+	}
+	for _, node := range candidates {
+		if len(chosen) == n {
+			break
+		}
+		if !picked[node] {
+			chosen = append(chosen, node)
+		}
+	}
+	return chosen
+}
@@ -0,0 +1,5 @@
+// Package kvstore contains the KeyValueService API. kvstore.proto is the
+// source of truth; the .pb.go files are generated from it with go generate.
+package kvstore
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative kvstore.proto
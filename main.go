@@ -1,120 +1,213 @@
 package main
 
 import (
-	"context"
+	"flag"
 	"log"
-	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
-	pb "distributed-kv-store/kvstore"
 	"distributed-kv-store/hash"
+	"distributed-kv-store/server"
 	"distributed-kv-store/store"
-
-	"google.golang.org/grpc"
 )
 
-// Server implements the KeyValueService and includes the hash ring.
-type Server struct {
-	pb.UnimplementedKeyValueServiceServer
-	store       *store.KeyValueStore
-	hashRing    *hash.HashRing
-	currentNode string
-	nodes       []string
-}
+func main() {
+	addr := flag.String("addr", "localhost:50051", "address this node listens on: host:port or unix:///path/to/socket")
+	nodes := flag.String("nodes", "localhost:50051,localhost:50052,localhost:50053", "comma-separated addresses of every node in the cluster")
+	zones := flag.String("zones", "", "comma-separated node=zone pairs recorded as node metadata")
+	zoneAware := flag.Bool("zone-aware", false, "place each key's replicas in distinct zones")
+	virtualNodes := flag.Int("virtual-nodes", 3, "virtual nodes per node on the hash ring")
+	maxVirtualNodes := flag.Int("max-virtual-nodes", 0, "cap on virtual nodes across the whole ring; per-node counts are scaled down to fit (0 means no cap, must match on every node)")
+	placementSalt := flag.String("placement-salt", "", "salt keys before placing them on the ring to spread sequential keys (must match on every node)")
+	replicationFactor := flag.Int("replication-factor", 1, "number of nodes responsible for each key")
+	requireReplicas := flag.Bool("require-replicas", false, "reject writes while the cluster has fewer nodes than -replication-factor instead of only warning")
+	redirect := flag.Bool("redirect", false, "reply to requests for keys owned by other nodes with a redirect instead of forwarding them")
+	responseCacheTTL := flag.Duration("response-cache-ttl", 0, "cache Get responses for keys owned by other nodes this long; reads may be stale by up to this much (0 disables)")
+	maxValueSize := flag.Int("max-value-size", 0, "maximum value size in bytes (0 means unlimited)")
+	allowEmptyKeys := flag.Bool("allow-empty-keys", false, "accept the empty string as a key instead of rejecting it")
+	capacityHint := flag.Int("capacity-hint", 0, "expected number of keys, used to preallocate the in-memory store (not a limit)")
+	quotas := flag.String("namespace-quotas", "", "comma-separated prefix=count pairs capping the keys each key prefix may hold on this node")
+	softDelete := flag.Duration("soft-delete-retention", 0, "keep deleted keys restorable with Undelete for this long (0 deletes immediately)")
+	deleteGrace := flag.Duration("delete-grace", 0, "report deleted keys as recently deleted in Get for this long (0 disables)")
+	ttlJitter := flag.Float64("ttl-jitter", 0, "expire keys up to this fraction of their TTL early, at random, so keys written together do not expire together (0 disables)")
+	skipExpired := flag.Bool("skip-expired-writes", false, "accept writes whose absolute expiry has already passed as no-ops instead of rejecting them")
+	compaction := flag.Float64("compaction-threshold", 0, "rebuild the in-memory map once overwrites and deletes exceed this multiple of its size (0 disables)")
+	coldDir := flag.String("cold-dir", "", "directory for the cold storage tier (empty disables it)")
+	seeds := flag.String("seeds", "", "comma-separated addresses of nodes to join through via gossip")
+	gossipInterval := flag.Duration("gossip-interval", 0, "how often to gossip membership (0 uses the static -nodes list only)")
+	ringState := flag.String("ring-state-file", "", "with gossip, save learned membership to this file and rejoin through it after a restart")
+	leaveTimeout := flag.Duration("leave-timeout", 0, "with gossip, on shutdown leave the cluster first and wait up to this long for peers to acknowledge it (0 stops without leaving)")
+	pins := flag.String("pins", "", "comma-separated key=node pairs routing each key to the given node instead of by the ring; use sparingly")
+	pinsVersion := flag.Uint64("pins-version", 0, "version of -pins; with gossip, nodes adopt the pins with the highest version")
+	bootstrap := flag.Bool("bootstrap", false, "after joining, pull the keys this node owns from the other nodes")
+	maxConcurrent := flag.Int("max-concurrent-requests", 0, "maximum requests handled at once before new ones wait (0 means unlimited)")
+	admissionWait := flag.Duration("admission-wait", 100*time.Millisecond, "how long a request waits for a free slot before failing with ResourceExhausted")
+	peerIdleTimeout := flag.Duration("peer-idle-timeout", 5*time.Minute, "close connections to peers unused for this long (0 keeps them open)")
+	peerMaxConns := flag.Int("peer-max-conns", 1, "maximum connections opened to each peer; more are dialed only while all are busy")
+	peerMinConns := flag.Int("peer-min-conns", 0, "connections to each peer kept open when idle")
+	peerConnStreams := flag.Int("peer-conn-streams", 32, "in-flight calls on every connection to a peer before another is dialed")
+	breakerThreshold := flag.Int("breaker-threshold", 5, "consecutive failures before forwarding to a peer fails fast (0 disables)")
+	breakerCooldown := flag.Duration("breaker-cooldown", 5*time.Second, "how long a peer's circuit breaker stays open before probing")
+	adminToken := flag.String("admin-token", "", "token required by admin RPCs such as Dump and Flush (empty disables them)")
+	clusterSecret := flag.String("cluster-secret", "", "secret shared by every node, proving calls between nodes come from a member (empty trusts calls from member hosts)")
+	dumpInterval := flag.Duration("dump-interval", time.Minute, "minimum time between two Dump calls")
+	aclFile := flag.String("acl-file", "", "JSON file of key prefix access rules per caller identity (empty allows everything); identities come from the unauthenticated x-caller-id header, so this is not a security boundary")
+	recordPath := flag.String("record", "", "append a sample of client requests to this file for replaying with cmd/replay (empty disables)")
+	recordRate := flag.Float64("record-sample-rate", 1, "fraction of client requests to record")
+	recordMax := flag.Int64("record-max-bytes", 64<<20, "stop recording once the file reaches this size (0 means unbounded)")
+	reflection := flag.Bool("reflection", false, "register the gRPC reflection service for tools like grpcurl (keep off in production)")
+	sloLatency := flag.Duration("slo-latency", 0, "p99 request latency target reported in Stats (0 disables the SLO)")
+	fanOutTimeout := flag.Duration("fan-out-timeout", 0, "overall time budget for operations that call every node, such as Scan and ClusterStats; nodes that miss it are reported and skipped (0 uses the request deadline only)")
+	requestTimeout := flag.Duration("request-timeout", 5*time.Second, "default deadline for requests that do not set one (0 disables)")
+	flag.Parse()
+
+	if *gossipInterval > 0 && *clusterSecret == "" {
+		log.Fatalf("-gossip-interval requires -cluster-secret, so that only members can change the ring")
+	}
 
-// Put inserts or updates a key-value pair.
-func (s *Server) Put(ctx context.Context, req *pb.PutRequest) (*pb.PutResponse, error) {
-	// Determine the responsible node for the key.
-	targetNode := s.hashRing.GetNode(req.Key)
-	if targetNode != s.currentNode {
-		// Forward the request to the responsible node via gRPC.
-		conn, err := grpc.Dial(targetNode, grpc.WithInsecure())
+	var cold store.ColdStore
+	if *coldDir != "" {
+		dirStore, err := store.NewDirColdStore(*coldDir)
 		if err != nil {
-			return nil, err
+			log.Fatalf("Failed to open cold store %s: %v", *coldDir, err)
 		}
-		defer conn.Close()
-
-		client := pb.NewKeyValueServiceClient(conn)
-		return client.Put(ctx, req)
+		cold = dirStore
 	}
 
-	// Handle the request locally.
-	s.store.Put(req.Key, req.Value)
-	return &pb.PutResponse{Success: true}, nil
-}
-
-// Get retrieves a value by key.
-func (s *Server) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
-	// Determine the responsible node for the key.
-	targetNode := s.hashRing.GetNode(req.Key)
-	if targetNode != s.currentNode {
-		// Forward the request to the responsible node via gRPC.
-		conn, err := grpc.Dial(targetNode, grpc.WithInsecure())
+	var acl []server.ACLRule
+	if *aclFile != "" {
+		rules, err := server.LoadACL(*aclFile)
 		if err != nil {
-			return nil, err
+			log.Fatalf("Failed to load ACL: %v", err)
 		}
-		defer conn.Close()
+		acl = rules
+	}
 
-		client := pb.NewKeyValueServiceClient(conn)
-		return client.Get(ctx, req)
+	var strategy hash.ReplicaStrategy = hash.NextN{}
+	if *zoneAware {
+		strategy = hash.ZoneAware{}
 	}
 
-	// Handle the request locally.
-	value, found := s.store.Get(req.Key)
-	return &pb.GetResponse{Value: value, Found: found}, nil
+	node := server.NewNode(server.Config{
+		Address:               *addr,
+		Nodes:                 splitList(*nodes),
+		NodeMetadata:          zoneMetadata(splitList(*zones)),
+		VirtualNodes:          *virtualNodes,
+		MaxVirtualNodes:       *maxVirtualNodes,
+		PlacementSalt:         *placementSalt,
+		ReplicationFactor:     *replicationFactor,
+		RequireReplicas:       *requireReplicas,
+		ReplicaStrategy:       strategy,
+		Redirect:              *redirect,
+		ResponseCacheTTL:      *responseCacheTTL,
+		Reflection:            *reflection,
+		SLOLatency:            *sloLatency,
+		RequestTimeout:        *requestTimeout,
+		FanOutTimeout:         *fanOutTimeout,
+		MaxValueSize:          *maxValueSize,
+		AllowEmptyKeys:        *allowEmptyKeys,
+		MaxConcurrentRequests: *maxConcurrent,
+		AdmissionWait:         *admissionWait,
+		PeerIdleTimeout:       *peerIdleTimeout,
+		PeerMinConns:          *peerMinConns,
+		PeerMaxConns:          *peerMaxConns,
+		PeerConnStreams:       *peerConnStreams,
+		BreakerThreshold:      *breakerThreshold,
+		BreakerCooldown:       *breakerCooldown,
+		AdminToken:            *adminToken,
+		ClusterSecret:         *clusterSecret,
+		ACL:                   acl,
+		RecordPath:            *recordPath,
+		RecordSampleRate:      *recordRate,
+		RecordMaxBytes:        *recordMax,
+		DumpInterval:          *dumpInterval,
+		CapacityHint:          *capacityHint,
+		NamespaceQuotas:       namespaceQuotas(splitList(*quotas)),
+		SoftDeleteRetention:   *softDelete,
+		DeleteGrace:           *deleteGrace,
+		SkipExpiredWrites:     *skipExpired,
+		TTLJitter:             *ttlJitter,
+		CompactionThreshold:   *compaction,
+		SweepInterval:         time.Minute,
+		ColdStore:             cold,
+		Seeds:                 splitList(*seeds),
+		GossipInterval:        *gossipInterval,
+		Bootstrap:             *bootstrap,
+		RingStateFile:         *ringState,
+		LeaveTimeout:          *leaveTimeout,
+		Pins:                  keyPins(splitList(*pins)),
+		PinsVersion:           *pinsVersion,
+	})
+
+	// On SIGINT or SIGTERM, stop accepting requests, let in-flight ones
+	// finish, drain background work and close the store before exiting.
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		sig := <-signals
+		log.Printf("Received %v, shutting down", sig)
+		node.Stop()
+	}()
+
+	if err := node.ListenAndServe(); err != nil {
+		log.Fatalf("Failed to serve: %v", err)
+	}
+	// Serve returns as soon as the listener closes; wait for Stop to finish.
+	node.Stop()
+	log.Printf("Node stopped")
 }
 
-// Delete removes a key-value pair.
-func (s *Server) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
-	// Determine the responsible node for the key.
-	targetNode := s.hashRing.GetNode(req.Key)
-	if targetNode != s.currentNode {
-		// Forward the request to the responsible node via gRPC.
-		conn, err := grpc.Dial(targetNode, grpc.WithInsecure())
-		if err != nil {
-			return nil, err
+// splitList splits a comma-separated flag value, ignoring empty entries.
+func splitList(s string) []string {
+	list := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
 		}
-		defer conn.Close()
-
-		client := pb.NewKeyValueServiceClient(conn)
-		return client.Delete(ctx, req)
 	}
-
-	// Handle the request locally.
-	s.store.Delete(req.Key)
-	return &pb.DeleteResponse{Success: true}, nil
+	return list
 }
 
-func main() {
-	// Define the nodes in the cluster.
-	nodes := []string{"localhost:50051", "localhost:50052", "localhost:50053"} // Example: 3 nodes
-	currentNode := "localhost:50051"                                         // Current node address
-
-	// Initialize the hash ring and add all nodes.
-	hashRing := hash.NewHashRing(3) // 3 replicas
-	for _, node := range nodes {
-		hashRing.AddNode(node)
-	}
-
-	// Initialize the store and server.
-	store := store.NewKeyValueStore()
-	server := &Server{
-		store:       store,
-		hashRing:    hashRing,
-		currentNode: currentNode,
-		nodes:       nodes,
+// zoneMetadata turns node=zone pairs into per-node metadata.
+func zoneMetadata(pairs []string) map[string]hash.Metadata {
+	meta := make(map[string]hash.Metadata)
+	for _, pair := range pairs {
+		node, zone, ok := strings.Cut(pair, "=")
+		if !ok {
+			log.Fatalf("Invalid -zones entry %q, expected node=zone", pair)
+		}
+		meta[node] = hash.Metadata{"zone": zone}
 	}
+	return meta
+}
 
-	// Start the gRPC server.
-	grpcServer := grpc.NewServer()
-	pb.RegisterKeyValueServiceServer(grpcServer, server)
-
-	listener, err := net.Listen("tcp", currentNode)
-	if err != nil {
-		log.Fatalf("Failed to listen on %s: %v", currentNode, err)
+// keyPins parses -pins entries of the form key=node.
+func keyPins(pairs []string) map[string]string {
+	pins := make(map[string]string)
+	for _, pair := range pairs {
+		key, node, ok := strings.Cut(pair, "=")
+		if !ok || key == "" || node == "" {
+			log.Fatalf("Invalid -pins entry %q, expected key=node", pair)
+		}
+		pins[key] = node
 	}
+	return pins
+}
 
-	log.Printf("Node %s is listening...", currentNode)
-	if err := grpcServer.Serve(listener); err != nil {
-		log.Fatalf("Failed to serve: %v", err)
+// namespaceQuotas turns prefix=count pairs into per-namespace key limits.
+func namespaceQuotas(pairs []string) map[string]int {
+	quotas := make(map[string]int)
+	for _, pair := range pairs {
+		prefix, count, ok := strings.Cut(pair, "=")
+		limit, err := strconv.Atoi(count)
+		if !ok || err != nil || limit < 0 {
+			log.Fatalf("Invalid -namespace-quotas entry %q, expected prefix=count", pair)
+		}
+		quotas[prefix] = limit
 	}
+	return quotas
 }
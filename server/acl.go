@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	pb "distributed-kv-store/kvstore"
+	"distributed-kv-store/store"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ACLRule grants an identity permissions on every key starting with Prefix.
+// Identities are the caller identities used for auditing (CallerMetadataKey,
+// or the peer address if it is not sent). The header is set by the client and
+// never verified, so any caller can claim any identity: rules keep
+// cooperating clients apart but are not a security boundary. "*" matches
+// every identity and an empty Prefix every key.
+type ACLRule struct {
+	Identity string `json:"identity"`
+	Prefix   string `json:"prefix"`
+	Read     bool   `json:"read"`   // Get, GetOrDefault, Scan, FindByIndex
+	Write    bool   `json:"write"`  // Put, PutContent, Touch, Undelete, Rename (on the new key)
+	Delete   bool   `json:"delete"` // Delete, BatchDelete, Rename (on the old key)
+}
+
+// LoadACL reads ACL rules from a JSON file holding a list of ACLRule objects.
+func LoadACL(path string) ([]ACLRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []ACLRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parse ACL %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+type permission int
+
+const (
+	permRead permission = iota
+	permWrite
+	permDelete
+)
+
+func (p permission) String() string {
+	return [...]string{"read", "write", "delete"}[p]
+}
+
+// acl checks key-level permissions. Requests from an identity are allowed
+// when at least one of its rules grants the permission on the key; RPCs that
+// do not touch key data, such as Locate or Stats, are not checked, and the
+// admin RPCs have their own token.
+type acl struct {
+	rules []ACLRule
+}
+
+func newACL(rules []ACLRule) *acl {
+	if len(rules) == 0 {
+		return nil
+	}
+	return &acl{rules: rules}
+}
+
+// allowed reports whether identity holds perm on key. With prefix set, key is
+// itself a prefix and the permission must cover every key starting with it.
+func (a *acl) allowed(identity, key string, perm permission) bool {
+	for _, rule := range a.rules {
+		if rule.Identity != identity && rule.Identity != "*" {
+			continue
+		}
+		if !strings.HasPrefix(key, rule.Prefix) {
+			continue
+		}
+		if perm == permRead && rule.Read || perm == permWrite && rule.Write || perm == permDelete && rule.Delete {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *acl) check(ctx context.Context, key string, perm permission) error {
+	identity := callerIdentity(ctx)
+	if !a.allowed(identity, key, perm) {
+		return status.Errorf(codes.PermissionDenied, "%s has no %s permission on %q", identity, perm, key)
+	}
+	return nil
+}
+
+func (a *acl) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var err error
+	switch req := req.(type) {
+	case *pb.GetRequest:
+		err = a.check(ctx, req.Key, permRead)
+	case *pb.GetOrDefaultRequest:
+		err = a.check(ctx, req.Key, permRead)
+	case *pb.FindByIndexRequest:
+		// Matches can be anywhere in the keyspace.
+		err = a.check(ctx, "", permRead)
+	case *pb.PutRequest:
+		err = a.check(ctx, req.Key, permWrite)
+	case *pb.PutContentRequest:
+		value := req.Value
+		if len(req.ValueBytes) > 0 {
+			value = string(req.ValueBytes)
+		}
+		err = a.check(ctx, store.ContentKey(value), permWrite)
+	case *pb.TouchRequest:
+		err = a.check(ctx, req.Key, permWrite)
+	case *pb.UndeleteRequest:
+		err = a.check(ctx, req.Key, permWrite)
+	case *pb.DeleteRequest:
+		err = a.check(ctx, req.Key, permDelete)
+	case *pb.BatchDeleteRequest:
+		for _, key := range req.Keys {
+			if err = a.check(ctx, key, permDelete); err != nil {
+				break
+			}
+		}
+	case *pb.RenameRequest:
+		if err = a.check(ctx, req.Key, permDelete); err == nil {
+			err = a.check(ctx, req.NewKey, permWrite)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// interceptStream checks Scan requests, which need read permission on their
+// whole prefix.
+func (a *acl) interceptStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &aclStream{ServerStream: stream, acl: a})
+}
+
+type aclStream struct {
+	grpc.ServerStream
+	acl *acl
+}
+
+func (s *aclStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if req, ok := m.(*pb.ScanRequest); ok {
+		return s.acl.check(s.Context(), req.Prefix, permRead)
+	}
+	return nil
+}
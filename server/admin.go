@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"sync"
+
+	pb "distributed-kv-store/kvstore"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AdminTokenMetadataKey is the request metadata key carrying the operator
+// token required by admin RPCs such as Dump and Flush.
+const AdminTokenMetadataKey = "x-admin-token"
+
+// authorizeAdmin checks the admin token sent with the request. Admin RPCs are
+// disabled on nodes without a token.
+func (s *Server) authorizeAdmin(ctx context.Context) error {
+	if s.adminToken == "" {
+		return status.Error(codes.FailedPrecondition, "admin operations are disabled on this node")
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	tokens := md.Get(AdminTokenMetadataKey)
+	if len(tokens) == 0 || subtle.ConstantTimeCompare([]byte(tokens[0]), []byte(s.adminToken)) != 1 {
+		return status.Error(codes.PermissionDenied, "invalid admin token")
+	}
+	return nil
+}
+
+// Flush deletes every key with the given prefix, or every key if the prefix
+// is empty, on every node in the ring. Tenants whose keys share a prefix can
+// be dropped cluster-wide this way. The receiving node broadcasts to all
+// nodes concurrently, retrying unreachable ones up to req.Retries times, and
+// reports the total deleted along with any node it could not reach. Nodes
+// that were reached are flushed even if others were not, so a partial
+// failure should be retried.
+func (s *Server) Flush(ctx context.Context, req *pb.FlushRequest) (*pb.FlushResponse, error) {
+	if err := s.authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	defer s.cache.clear()
+	if req.Local {
+		return &pb.FlushResponse{Deleted: int64(s.store.DeletePrefix(req.Prefix))}, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = metadata.AppendToOutgoingContext(ctx, AdminTokenMetadataKey, md.Get(AdminTokenMetadataKey)[0])
+
+	var (
+		mu   sync.Mutex
+		resp = &pb.FlushResponse{}
+		wg   sync.WaitGroup
+	)
+	nodeCtx, cancel := s.fanOutContext(ctx)
+	defer cancel()
+	for _, node := range s.hashRing.Nodes() {
+		wg.Add(1)
+		go func(node string) {
+			defer wg.Done()
+			deleted, err := s.flushNode(nodeCtx, node, req)
+			for attempt := int32(0); err != nil && attempt < req.Retries && status.Code(err) == codes.Unavailable; attempt++ {
+				deleted, err = s.flushNode(nodeCtx, node, req)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Deleted += deleted
+			if err != nil {
+				resp.Failed = append(resp.Failed, nodeError(ctx, node, err))
+			}
+		}(node)
+	}
+	wg.Wait()
+	return resp, nil
+}
+
+// flushNode flushes one node's local store.
+func (s *Server) flushNode(ctx context.Context, node string, req *pb.FlushRequest) (int64, error) {
+	if node == s.currentNode {
+		return int64(s.store.DeletePrefix(req.Prefix)), nil
+	}
+	client, err := s.peer(node)
+	if err != nil {
+		return 0, toStatus(err)
+	}
+	resp, err := client.Flush(ctx, &pb.FlushRequest{Prefix: req.Prefix, Local: true})
+	if err != nil {
+		return 0, toStatus(s.forwardErr(node, err))
+	}
+	s.recordHealth(node, nil)
+	return resp.Deleted, nil
+}
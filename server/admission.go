@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// PriorityMetadataKey is the request metadata key carrying a request's
+// priority: "high", "normal" (the default) or "low". When a node is at its
+// concurrency limit, waiting requests are admitted highest priority first.
+// Forwarded requests keep the priority of the original request.
+const PriorityMetadataKey = "x-priority"
+
+// priority orders requests waiting for admission.
+type priority int
+
+const (
+	priorityLow priority = iota
+	priorityNormal
+	priorityHigh
+	numPriorities
+)
+
+// requestPriority returns the priority the client asked for.
+func requestPriority(ctx context.Context) priority {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if p := md.Get(PriorityMetadataKey); len(p) > 0 {
+			switch p[0] {
+			case "high":
+				return priorityHigh
+			case "low":
+				return priorityLow
+			}
+		}
+	}
+	return priorityNormal
+}
+
+// admission bounds how many requests run their handlers at once. A request
+// that cannot get a slot within wait fails with ResourceExhausted instead of
+// queueing indefinitely, and freed slots go to the highest priority waiter,
+// so under sustained load low priority requests are the ones shed. Traffic
+// from other nodes (forwarded requests and gossip) draws from its own pool,
+// so a flood of client requests cannot stall the cluster's internal calls
+// and a node's forwarding cannot be starved by its peers' clients.
+type admission struct {
+	clients  *prioritySemaphore
+	internal *prioritySemaphore
+	wait     time.Duration
+}
+
+func newAdmission(limit int, wait time.Duration) *admission {
+	return &admission{
+		clients:  newPrioritySemaphore(limit),
+		internal: newPrioritySemaphore(limit),
+		wait:     wait,
+	}
+}
+
+func (a *admission) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	pool := a.clients
+	if forwardedBy(ctx) != "" || strings.HasSuffix(info.FullMethod, "/Gossip") {
+		pool = a.internal
+	}
+	if err := pool.acquire(ctx, requestPriority(ctx), a.wait); err != nil {
+		return nil, err
+	}
+	defer pool.release()
+	return handler(ctx, req)
+}
+
+// prioritySemaphore is a counting semaphore whose waiters are served highest
+// priority first, then in arrival order.
+type prioritySemaphore struct {
+	mu      sync.Mutex
+	free    int
+	waiters [numPriorities][]chan struct{}
+}
+
+func newPrioritySemaphore(n int) *prioritySemaphore {
+	return &prioritySemaphore{free: n}
+}
+
+// acquire takes a slot, waiting up to wait for one to be handed over.
+func (s *prioritySemaphore) acquire(ctx context.Context, p priority, wait time.Duration) error {
+	s.mu.Lock()
+	if s.free > 0 {
+		s.free--
+		s.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	s.waiters[p] = append(s.waiters[p], ready)
+	s.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-ready:
+		return nil
+	case <-timer.C:
+		err = status.Error(codes.ResourceExhausted, "server is at its concurrent request limit")
+	case <-ctx.Done():
+		err = status.FromContextError(ctx.Err()).Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, w := range s.waiters[p] {
+		if w == ready {
+			s.waiters[p] = append(s.waiters[p][:i], s.waiters[p][i+1:]...)
+			return err
+		}
+	}
+	// release handed us the slot just as we gave up; pass it on.
+	s.releaseLocked()
+	return err
+}
+
+// release returns a slot, handing it straight to the best waiter if any.
+func (s *prioritySemaphore) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseLocked()
+}
+
+func (s *prioritySemaphore) releaseLocked() {
+	for p := numPriorities - 1; p >= 0; p-- {
+		if len(s.waiters[p]) > 0 {
+			ready := s.waiters[p][0]
+			s.waiters[p] = s.waiters[p][1:]
+			close(ready)
+			return
+		}
+	}
+	s.free++
+}
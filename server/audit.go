@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// CallerMetadataKey is the request metadata key clients use to identify themselves.
+const CallerMetadataKey = "x-caller-id"
+
+// AuditEvent records one data access.
+type AuditEvent struct {
+	Time      time.Time
+	Operation string // RPC name, e.g. "Put"
+	Key       string
+	Caller    string     // CallerMetadataKey if sent, otherwise the peer address
+	Result    codes.Code // codes.OK on success
+}
+
+// Auditor receives audit events. Audit is called from a single background
+// goroutine, never on the request path.
+type Auditor interface {
+	Audit(AuditEvent)
+}
+
+// auditedOperations are the RPCs that read or write key data.
+var auditedOperations = map[string]bool{
+	"Put":          true,
+	"PutContent":   true,
+	"Get":          true,
+	"GetOrDefault": true,
+	"Delete":       true,
+	"Touch":        true,
+	"Undelete":     true,
+	"Rename":       true,
+	"BatchDelete":  true,
+}
+
+// auditLog buffers events between request handlers and the Auditor. When the
+// buffer is full events are dropped and counted rather than slowing requests.
+type auditLog struct {
+	auditor Auditor
+	events  chan AuditEvent
+	dropped atomic.Uint64
+}
+
+func newAuditLog(auditor Auditor, buffer int) *auditLog {
+	if buffer < 1 {
+		buffer = 1024
+	}
+	return &auditLog{auditor: auditor, events: make(chan AuditEvent, buffer)}
+}
+
+func (a *auditLog) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	operation := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]
+	if !auditedOperations[operation] {
+		return resp, err
+	}
+	event := AuditEvent{
+		Time:      time.Now(),
+		Operation: operation,
+		Caller:    callerIdentity(ctx),
+		Result:    status.Code(err),
+	}
+	if keyed, ok := req.(interface{ GetKey() string }); ok {
+		event.Key = keyed.GetKey()
+	}
+	select {
+	case a.events <- event:
+	default:
+		a.dropped.Add(1)
+	}
+	return resp, err
+}
+
+// run delivers buffered events until ctx is done, then flushes what is left.
+func (a *auditLog) run(ctx context.Context) {
+	for {
+		select {
+		case event := <-a.events:
+			a.auditor.Audit(event)
+		case <-ctx.Done():
+			for {
+				select {
+				case event := <-a.events:
+					a.auditor.Audit(event)
+				default:
+					if n := a.dropped.Load(); n > 0 {
+						log.Printf("Dropped %d audit events because the buffer was full", n)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// callerIdentity returns the caller's self-reported identity, falling back to its address.
+func callerIdentity(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(CallerMetadataKey); len(ids) > 0 {
+			return ids[0]
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
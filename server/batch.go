@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	pb "distributed-kv-store/kvstore"
+	"distributed-kv-store/store"
+)
+
+// BatchDelete deletes many keys at once. Keys are grouped by the node that
+// owns them and each group is sent in one call, concurrently. The response
+// has one result per requested key, in request order, saying whether the key
+// existed; keys whose node could not be reached, or that could not be
+// deleted, carry an error instead of failing the whole batch.
+func (s *Server) BatchDelete(ctx context.Context, req *pb.BatchDeleteRequest) (*pb.BatchDeleteResponse, error) {
+	defer s.cache.invalidate(req.Keys...)
+
+	results := make([]*pb.KeyDeleteResult, len(req.Keys))
+	groups := make(map[string][]int) // node -> indexes into req.Keys
+	for i, key := range req.Keys {
+		results[i] = &pb.KeyDeleteResult{Key: key}
+		node, err := s.route(ctx, key)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		groups[node] = append(groups[node], i)
+	}
+
+	nodeCtx, cancel := s.fanOutContext(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	for node, indexes := range groups {
+		if node == s.currentNode {
+			s.deleteLocal(results, indexes)
+			continue
+		}
+		wg.Add(1)
+		go func(node string, indexes []int) {
+			defer wg.Done()
+			s.deleteRemote(nodeCtx, node, results, indexes)
+		}(node, indexes)
+	}
+	wg.Wait()
+	return &pb.BatchDeleteResponse{Results: results}, nil
+}
+
+// deleteLocal deletes the keys at indexes from this node's store.
+func (s *Server) deleteLocal(results []*pb.KeyDeleteResult, indexes []int) {
+	for _, i := range indexes {
+		err := s.store.Delete(results[i].Key)
+		switch {
+		case err == nil:
+			results[i].Existed = true
+		case !errors.Is(err, store.ErrKeyNotFound):
+			results[i].Error = err.Error()
+		}
+	}
+}
+
+// deleteRemote sends the keys at indexes to node in one BatchDelete and
+// copies its answers into results. If the call fails, every one of those
+// keys is marked with the error.
+func (s *Server) deleteRemote(ctx context.Context, node string, results []*pb.KeyDeleteResult, indexes []int) {
+	fail := func(err error) {
+		for _, i := range indexes {
+			results[i].Error = err.Error()
+		}
+	}
+	if s.redirect {
+		fail(redirectErr(node))
+		return
+	}
+	client, err := s.peer(node)
+	if err != nil {
+		fail(err)
+		return
+	}
+	keys := make([]string, len(indexes))
+	for j, i := range indexes {
+		keys[j] = results[i].Key
+	}
+	resp, err := client.BatchDelete(s.forwardContext(ctx), &pb.BatchDeleteRequest{Keys: keys})
+	if err != nil {
+		fail(s.forwardErr(node, err))
+		return
+	}
+	s.recordHealth(node, nil)
+	if len(resp.Results) != len(indexes) {
+		fail(fmt.Errorf("node %s answered for %d keys instead of %d", node, len(resp.Results), len(indexes)))
+		return
+	}
+	for j, i := range indexes {
+		if resp.Results[j].Key != keys[j] {
+			results[i].Error = fmt.Sprintf("node %s answered for key %q instead of %q", node, resp.Results[j].Key, keys[j])
+			continue
+		}
+		results[i].Existed = resp.Results[j].Existed
+		results[i].Error = resp.Results[j].Error
+	}
+}
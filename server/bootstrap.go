@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	pb "distributed-kv-store/kvstore"
+	"distributed-kv-store/store"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// bootstrap pulls the keys this node owns under the current ring from every
+// other node, streaming each peer's local Scan. A key is only imported if it
+// is absent here or still holds the value a previous pass imported, so writes
+// that reached this node directly are never overwritten. imported records the
+// version each import was stored at across passes. Previous owners keep
+// their copies; they are no longer routed to, and cluster Scan and
+// ClusterStats only count keys on their primary, so they are not seen twice.
+func (s *Server) bootstrap(ctx context.Context, imported map[string]uint64) (int, error) {
+	total := 0
+	var firstErr error
+	for _, node := range s.hashRing.Nodes() {
+		if node == s.currentNode {
+			continue
+		}
+		n, err := s.pullFrom(ctx, node, imported)
+		total += n
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return total, firstErr
+}
+
+// pullFrom imports the keys this node owns from one peer.
+func (s *Server) pullFrom(ctx context.Context, node string, imported map[string]uint64) (int, error) {
+	client, err := s.peer(node)
+	if err != nil {
+		return 0, err
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, CallerMetadataKey, s.currentNode)
+	stream, err := client.Scan(ctx, &pb.ScanRequest{Local: true})
+	if err != nil {
+		return 0, s.forwardErr(node, err)
+	}
+
+	count := 0
+	for {
+		kv, err := stream.Recv()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, s.forwardErr(node, err)
+		}
+		if s.primary(kv.Key) != s.currentNode {
+			continue
+		}
+
+		opts, live := importOptions(kv)
+		if !live {
+			continue
+		}
+		expected := imported[kv.Key] // 0, meaning absent, unless an earlier pass imported it
+		opts.ExpectedVersion = &expected
+		value := kv.Value
+		if len(kv.ValueBytes) > 0 {
+			value = string(kv.ValueBytes)
+		}
+		version, err := s.store.PutWithOptions(kv.Key, value, opts)
+		if errors.Is(err, store.ErrVersionConflict) {
+			continue // written here since; the local value is newer
+		}
+		if err != nil {
+			return count, err
+		}
+		imported[kv.Key] = version
+		count++
+	}
+}
+
+// importOptions carries a transferred key's expiry and content type over.
+// live is false if it has already expired.
+func importOptions(kv *pb.KeyValue) (opts store.PutOptions, live bool) {
+	opts.ContentType = kv.ContentType
+	if kv.ExpiresAtUnixMs == 0 {
+		return opts, true
+	}
+	if kv.SlidingTtlMs > 0 {
+		opts.TTL = time.Duration(kv.SlidingTtlMs) * time.Millisecond
+		opts.SlidingTTL = true
+	} else {
+		opts.TTL = time.Until(time.UnixMilli(kv.ExpiresAtUnixMs))
+	}
+	return opts, opts.TTL > 0
+}
+
+// bootstrap runs two pull passes after the node starts: one once gossip has
+// had time to announce it, and one after FailureTimeout, by which point every
+// peer has routed new writes here and any that landed on a previous owner in
+// between can be picked up.
+func (n *Node) bootstrap(ctx context.Context) {
+	imported := make(map[string]uint64)
+	for _, wait := range []time.Duration{2 * n.config.GossipInterval, n.config.FailureTimeout} {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		count, err := n.server.bootstrap(ctx, imported)
+		if err != nil {
+			log.Printf("Bootstrap pass imported %d keys with errors: %v", count, err)
+		} else {
+			log.Printf("Bootstrap pass imported %d keys", count)
+		}
+	}
+}
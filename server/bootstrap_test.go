@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	pb "distributed-kv-store/kvstore"
+)
+
+// TestBootstrapLeavesNoDuplicates imports keys from their previous owner,
+// which keeps its copies, and checks that cluster Scan and ClusterStats see
+// each key once, with the value written after the handoff, and that a Delete
+// through the new owner takes the key out of Scan.
+func TestBootstrapLeavesNoDuplicates(t *testing.T) {
+	nodes := startCluster(t, 2, nil)
+	previous, joined := nodes[0], nodes[1]
+
+	// Keys the joined node owns, written to the previous owner before the join.
+	var keys []string
+	for i := 0; len(keys) < 10; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if joined.server.primary(key) == joined.Address() {
+			if err := previous.Store().Put(key, "old"); err != nil {
+				t.Fatal(err)
+			}
+			keys = append(keys, key)
+		}
+	}
+	ctx := context.Background()
+	if n, err := joined.server.bootstrap(ctx, make(map[string]uint64)); err != nil || n != len(keys) {
+		t.Fatalf("bootstrap imported %d keys, err %v; want %d", n, err, len(keys))
+	}
+
+	client := dial(t, previous)
+	for _, key := range keys {
+		if _, err := client.Put(ctx, &pb.PutRequest{Key: key, Value: "new"}); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+	scan := func() map[string][]string {
+		stream, err := client.Scan(ctx, &pb.ScanRequest{})
+		if err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		seen := make(map[string][]string)
+		for {
+			kv, err := stream.Recv()
+			if err == io.EOF {
+				return seen
+			}
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			seen[kv.Key] = append(seen[kv.Key], kv.Value)
+		}
+	}
+	seen := scan()
+	for _, key := range keys {
+		if values := seen[key]; len(values) != 1 || values[0] != "new" {
+			t.Errorf("Scan returned %s as %q, want once as \"new\"", key, values)
+		}
+	}
+	stats, err := client.ClusterStats(ctx, &pb.ClusterStatsRequest{})
+	if err != nil {
+		t.Fatalf("ClusterStats: %v", err)
+	}
+	if stats.Total.Keys != int64(len(keys)) {
+		t.Errorf("ClusterStats counted %d keys, want %d", stats.Total.Keys, len(keys))
+	}
+
+	for _, key := range keys {
+		if _, err := client.Delete(ctx, &pb.DeleteRequest{Key: key}); err != nil {
+			t.Fatalf("Delete(%s): %v", key, err)
+		}
+	}
+	if seen := scan(); len(seen) != 0 {
+		t.Errorf("Scan after Delete returned %v, want nothing", seen)
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// breakerSet holds a circuit breaker per peer. After threshold consecutive
+// failed calls to a peer its breaker opens and forwarded calls fail fast.
+// Once cooldown has passed one probe call is let through (half-open): success
+// closes the breaker, failure opens it for another cooldown.
+type breakerSet struct {
+	mu        sync.Mutex
+	threshold int // 0 disables the breakers
+	cooldown  time.Duration
+	peers     map[string]*breaker
+}
+
+type breaker struct {
+	failures int
+	open     bool
+	halfOpen bool
+	openedAt time.Time
+}
+
+func newBreakerSet(threshold int, cooldown time.Duration) *breakerSet {
+	return &breakerSet{threshold: threshold, cooldown: cooldown, peers: make(map[string]*breaker)}
+}
+
+// allow reports whether a call to node may proceed.
+func (bs *breakerSet) allow(node string) bool {
+	if bs.threshold <= 0 {
+		return true
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.peers[node]
+	if !ok || !b.open {
+		return true
+	}
+	if time.Since(b.openedAt) < bs.cooldown {
+		return false
+	}
+	// Let a single probe through; restarting the clock keeps further calls
+	// failing fast until the probe reports back.
+	b.halfOpen = true
+	b.openedAt = time.Now()
+	return true
+}
+
+// record updates node's breaker with the outcome of a call.
+func (bs *breakerSet) record(node string, failed bool) {
+	if bs.threshold <= 0 {
+		return
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.peers[node]
+	if !ok {
+		b = &breaker{}
+		bs.peers[node] = b
+	}
+	if !failed {
+		*b = breaker{}
+		return
+	}
+	b.failures++
+	if b.halfOpen || b.failures >= bs.threshold {
+		b.open = true
+		b.halfOpen = false
+		b.openedAt = time.Now()
+	}
+}
+
+// states returns the state of every breaker: "closed", "open" or "half-open".
+func (bs *breakerSet) states() map[string]string {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	states := make(map[string]string, len(bs.peers))
+	for node, b := range bs.peers {
+		switch {
+		case b.halfOpen:
+			states[node] = "half-open"
+		case b.open:
+			states[node] = "open"
+		default:
+			states[node] = "closed"
+		}
+	}
+	return states
+}
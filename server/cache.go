@@ -0,0 +1,105 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	pb "distributed-kv-store/kvstore"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// maxCachedResponses bounds the response cache. Once it is full, expired
+// entries are swept and new responses are not cached until there is room.
+const maxCachedResponses = 10000
+
+// responseCache keeps Get responses for keys owned by other nodes for a short
+// TTL so hot remote keys are not forwarded on every read. It only sees the
+// writes this node coordinates, so a write sent to another node is not
+// visible through it until the entry expires: cached reads can be up to ttl
+// stale. A nil cache caches nothing.
+type responseCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[cacheKey]cachedResponse
+}
+
+type cacheKey struct {
+	key     string
+	asBytes bool
+}
+
+type cachedResponse struct {
+	resp    *pb.GetResponse
+	expires time.Time
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &responseCache{ttl: ttl, entries: make(map[cacheKey]cachedResponse)}
+}
+
+// get returns a copy of the cached response for req, if there is a fresh one.
+func (c *responseCache) get(req *pb.GetRequest) (*pb.GetResponse, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k := cacheKey{req.Key, req.AsBytes}
+	cached, ok := c.entries[k]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(cached.expires) {
+		delete(c.entries, k)
+		return nil, false
+	}
+	return proto.Clone(cached.resp).(*pb.GetResponse), true
+}
+
+// put caches a response to req. Misses are not cached.
+func (c *responseCache) put(req *pb.GetRequest, resp *pb.GetResponse) {
+	if c == nil || !resp.Found {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= maxCachedResponses {
+		for k, cached := range c.entries {
+			if now.After(cached.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCachedResponses {
+			return
+		}
+	}
+	c.entries[cacheKey{req.Key, req.AsBytes}] = cachedResponse{resp: proto.Clone(resp).(*pb.GetResponse), expires: now.Add(c.ttl)}
+}
+
+// invalidate drops the cached responses for keys written through this node.
+func (c *responseCache) invalidate(keys ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.entries, cacheKey{key, false})
+		delete(c.entries, cacheKey{key, true})
+	}
+}
+
+// clear drops every cached response.
+func (c *responseCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+}
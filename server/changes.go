@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"distributed-kv-store/store"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ChangeSink publishes the writes committed on a node, for example to a
+// Kafka topic or NATS subject feeding a downstream pipeline.
+//
+// Publish is called from a single background goroutine, never on the
+// request path, with the node's changes in the order they were committed.
+// Delivery is at least once: a change whose Publish returns an error is
+// retried until it succeeds, so a sink that fails after publishing may see
+// it twice. Changes are buffered in memory, so those not yet published when
+// the process crashes are lost; on Stop the node keeps publishing what is
+// buffered for up to the ChangeSinkDrain timeout. There is no ordering
+// between nodes, but each key is written on one node, so every key's
+// changes arrive in version order.
+type ChangeSink interface {
+	Publish(store.Change) error
+}
+
+// NopChangeSink discards every change.
+type NopChangeSink struct{}
+
+func (NopChangeSink) Publish(store.Change) error { return nil }
+
+// changeRetryDelay is how long the feed waits before retrying a failed Publish.
+const changeRetryDelay = 100 * time.Millisecond
+
+// changeFeed buffers changes between the store and the sink. Queuing never
+// blocks, since the store reports changes under its write lock. Instead,
+// while more than limit changes are waiting, new write requests are held
+// back until the sink catches up or their deadline passes.
+type changeFeed struct {
+	sink  ChangeSink
+	limit int
+	drain time.Duration
+
+	mu      sync.Mutex
+	pending []store.Change
+	ready   chan struct{} // signalled when changes are queued
+	room    chan struct{} // closed and replaced when the queue drops below limit
+}
+
+func newChangeFeed(sink ChangeSink, limit int, drain time.Duration) *changeFeed {
+	if limit < 1 {
+		limit = 4096
+	}
+	if drain <= 0 {
+		drain = 5 * time.Second
+	}
+	return &changeFeed{
+		sink:  sink,
+		limit: limit,
+		drain: drain,
+		ready: make(chan struct{}, 1),
+		room:  make(chan struct{}),
+	}
+}
+
+// add queues a committed change. It is the store's ChangeHook.
+func (f *changeFeed) add(change store.Change) {
+	f.mu.Lock()
+	f.pending = append(f.pending, change)
+	f.mu.Unlock()
+	select {
+	case f.ready <- struct{}{}:
+	default:
+	}
+}
+
+// intercept holds back write requests while the feed is over its limit.
+func (f *changeFeed) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if operation := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]; writeOperations[operation] {
+		for {
+			f.mu.Lock()
+			full, room := len(f.pending) >= f.limit, f.room
+			f.mu.Unlock()
+			if !full {
+				break
+			}
+			select {
+			case <-room:
+			case <-ctx.Done():
+				return nil, status.Error(codes.ResourceExhausted, "change feed is backed up")
+			}
+		}
+	}
+	return handler(ctx, req)
+}
+
+// writeOperations are the RPCs that can commit changes.
+var writeOperations = map[string]bool{
+	"Put":         true,
+	"PutContent":  true,
+	"Delete":      true,
+	"Undelete":    true,
+	"Rename":      true,
+	"BatchDelete": true,
+	"Flush":       true,
+}
+
+// run publishes queued changes until ctx is done, then keeps publishing what
+// is left for up to the drain timeout.
+func (f *changeFeed) run(ctx context.Context) {
+	for {
+		if !f.publishPending(ctx) {
+			break
+		}
+		select {
+		case <-f.ready:
+		case <-ctx.Done():
+		}
+	}
+	drainCtx, cancel := context.WithTimeout(context.Background(), f.drain)
+	defer cancel()
+	f.publishPending(drainCtx)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if n := len(f.pending); n > 0 {
+		log.Printf("Stopped with %d changes unpublished", n)
+	}
+}
+
+// publishPending publishes queued changes in order, retrying failures, until
+// the queue is empty or ctx is done. It reports whether ctx is still live.
+func (f *changeFeed) publishPending(ctx context.Context) bool {
+	for {
+		f.mu.Lock()
+		if len(f.pending) == 0 {
+			f.mu.Unlock()
+			return ctx.Err() == nil
+		}
+		change := f.pending[0]
+		f.mu.Unlock()
+
+		for {
+			err := f.sink.Publish(change)
+			if err == nil {
+				break
+			}
+			log.Printf("Failed to publish change to %q, retrying: %v", change.Key, err)
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(changeRetryDelay):
+			}
+		}
+
+		f.mu.Lock()
+		f.pending = f.pending[1:]
+		if len(f.pending) == f.limit-1 {
+			close(f.room)
+			f.room = make(chan struct{})
+		}
+		f.mu.Unlock()
+	}
+}
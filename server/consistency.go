@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"hash/crc32"
+	"sort"
+	"strings"
+	"sync"
+
+	pb "distributed-kv-store/kvstore"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// CheckConsistency streams the selected keys whose copies disagree with the
+// copy the write path creates. Writes are stored only on a key's primary
+// (its pinned node, or the ring's first node), so a copy anywhere else is
+// compared with the primary's: a node that is not one of the key's replicas
+// holding it at all, or a replica holding it while the primary lacks it or
+// holds a different value, is reported. A replica merely missing the key is
+// expected until writes are replicated. Such copies are left behind when
+// ownership moves. A summary is sent last. Nothing is repaired.
+//
+// Every node is scanned concurrently within the fan-out budget and copies
+// are checked as they arrive, so memory does not grow with the number of
+// keys. Nodes cut off by the budget are listed in the
+// TimedOutNodesMetadataKey trailer. It requires the admin token.
+func (s *Server) CheckConsistency(req *pb.ConsistencyRequest, stream pb.KeyValueService_CheckConsistencyServer) error {
+	if err := s.authorizeAdmin(stream.Context()); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	type heldCopy struct {
+		node string
+		kv   *pb.KeyValue
+	}
+	copies := make(chan heldCopy)
+	var (
+		mu       sync.Mutex
+		firstErr error
+		late     []string // nodes cut off by the fan-out budget
+		wg       sync.WaitGroup
+	)
+	nodeCtx, cancelNodes := s.fanOutContext(ctx)
+	defer cancelNodes()
+	scan := &pb.ScanRequest{Prefix: req.Prefix, Range: req.Range, Local: true}
+	for _, node := range s.hashRing.Nodes() {
+		wg.Add(1)
+		go func(node string) {
+			defer wg.Done()
+			results := make(chan *pb.KeyValue)
+			done := make(chan error, 1)
+			go func() {
+				done <- s.scanNode(nodeCtx, node, scan, nil, results)
+				close(results)
+			}()
+			for kv := range results {
+				select {
+				case copies <- heldCopy{node: node, kv: kv}:
+				case <-nodeCtx.Done():
+				}
+			}
+			err := <-done
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+			case timedOut(ctx, err):
+				late = append(late, node)
+			case firstErr == nil:
+				firstErr = err
+			}
+		}(node)
+	}
+	go func() {
+		wg.Wait()
+		close(copies)
+	}()
+
+	// gRPC streams are not safe for concurrent sends, so one loop does them all.
+	summary := &pb.ConsistencySummary{}
+	for held := range copies {
+		summary.Checked++
+		primary := s.primary(held.kv.Key)
+		if held.node == primary {
+			continue
+		}
+		states, divergent, err := s.copyStates(nodeCtx, held.node, held.kv, primary)
+		if err != nil {
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			mu.Unlock()
+			cancelNodes()
+			continue
+		}
+		if !divergent {
+			continue
+		}
+		summary.Divergent++
+		report := &pb.ConsistencyReport{Report: &pb.ConsistencyReport_Divergence{
+			Divergence: &pb.KeyDivergence{Key: held.kv.Key, Replicas: states},
+		}}
+		if err := stream.Send(report); err != nil {
+			return err
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	if len(late) > 0 {
+		sort.Strings(late)
+		stream.SetTrailer(metadata.Pairs(TimedOutNodesMetadataKey, strings.Join(late, ",")))
+	}
+	return stream.Send(&pb.ConsistencyReport{Report: &pb.ConsistencyReport_Summary{Summary: summary}})
+}
+
+// copyStates compares a copy of kv found on node, which is not the key's
+// primary, with the primary's copy. It returns both states, the primary's
+// first, and whether they diverge.
+func (s *Server) copyStates(ctx context.Context, node string, kv *pb.KeyValue, primary string) ([]*pb.ReplicaState, bool, error) {
+	held := &pb.ReplicaState{
+		Node:     node,
+		Replica:  contains(s.replicas(kv.Key), node),
+		Found:    true,
+		Version:  kv.Version,
+		Checksum: checksum(kv.Value, kv.ValueBytes),
+	}
+	owner, err := s.primaryState(ctx, kv.Key, primary)
+	if err != nil {
+		return nil, false, err
+	}
+	divergent := !held.Replica || !owner.Found || owner.Checksum != held.Checksum
+	return []*pb.ReplicaState{owner, held}, divergent, nil
+}
+
+// primaryState reads key's copy on its primary.
+func (s *Server) primaryState(ctx context.Context, key, primary string) (*pb.ReplicaState, error) {
+	var resp *pb.GetResponse
+	req := &pb.GetRequest{Key: key, LocalOnly: true, AsBytes: true}
+	if primary == s.currentNode {
+		resp = s.getLocal(req)
+	} else {
+		client, err := s.peer(primary)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, CallerMetadataKey, callerIdentity(ctx))
+		if resp, err = client.Get(ctx, req); err != nil {
+			return nil, toStatus(s.forwardErr(primary, err))
+		}
+	}
+	state := &pb.ReplicaState{Node: primary, Replica: true, Found: resp.Found}
+	if resp.Found {
+		state.Version = resp.Version
+		state.Checksum = checksum(resp.Value, resp.ValueBytes)
+	}
+	return state, nil
+}
+
+// checksum returns the crc32 of a value in its wire form.
+func checksum(value string, valueBytes []byte) uint32 {
+	if len(valueBytes) > 0 {
+		return crc32.ChecksumIEEE(valueBytes)
+	}
+	return crc32.ChecksumIEEE([]byte(value))
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	pb "distributed-kv-store/kvstore"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// dumpGate limits how often Dump may run: one at a time, and no more often
+// than once per interval.
+type dumpGate struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	running bool
+	last    time.Time
+}
+
+// acquire checks the rate limit. On success the returned function must be
+// called when the dump finishes.
+func (g *dumpGate) acquire() (func(), error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.running {
+		return nil, status.Error(codes.ResourceExhausted, "a dump is already running")
+	}
+	if wait := g.interval - time.Since(g.last); !g.last.IsZero() && wait > 0 {
+		return nil, status.Errorf(codes.ResourceExhausted, "dump rate limited, retry in %s", wait.Round(time.Second))
+	}
+	g.running = true
+	g.last = time.Now()
+	return func() {
+		g.mu.Lock()
+		g.running = false
+		g.mu.Unlock()
+	}, nil
+}
+
+// Dump streams every key held by this node. It is an operator tool for
+// debugging and small deployments, not a query API: it requires the admin
+// token, only reads the local store, and is rate limited. Entries are
+// streamed as the store is iterated, the same way as a local Scan.
+func (s *Server) Dump(req *pb.DumpRequest, stream pb.KeyValueService_DumpServer) error {
+	if err := s.authorizeAdmin(stream.Context()); err != nil {
+		return err
+	}
+	release, err := s.dumps.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	return s.scanLocal(stream.Context(), &pb.ScanRequest{}, nil, stream.Send)
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"errors"
+
+	"distributed-kv-store/hash"
+	"distributed-kv-store/store"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrNodeUnavailable is returned when a request could not be forwarded to the node that owns the key.
+var ErrNodeUnavailable = errors.New("node unavailable")
+
+// ErrWrongNode is returned when a forwarded request reaches a node that does not own the key.
+var ErrWrongNode = errors.New("wrong node")
+
+// ErrUnderReplicated is returned for writes while the cluster has fewer
+// nodes than the replication factor and RequireReplicas is set.
+var ErrUnderReplicated = errors.New("not enough nodes for the replication factor")
+
+// ErrCrossNodeRename is returned when a Rename would move a key to another
+// node, which cannot be done atomically.
+var ErrCrossNodeRename = errors.New("old and new keys are owned by different nodes")
+
+// toStatus maps an error to a gRPC status error. Errors that already carry a
+// status, such as those returned by a forwarded call, are passed through.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch {
+	case errors.Is(err, store.ErrKeyNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, store.ErrValueTooLarge), errors.Is(err, store.ErrExpiryInPast),
+		errors.Is(err, store.ErrValueRejected), errors.Is(err, store.ErrEmptyKey):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, store.ErrQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, store.ErrVersionConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, hash.ErrRingEmpty):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrWrongNode), errors.Is(err, ErrCrossNodeRename):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, ErrNodeUnavailable), errors.Is(err, ErrUnderReplicated):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Error(codes.Internal, err.Error())
+}
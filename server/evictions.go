@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"distributed-kv-store/store"
+)
+
+// evictionFeed runs the EvictionCallback off the write path. The store
+// reports evictions under its write lock, so they are queued and handed to
+// the callback one at a time, in order, from a background goroutine. The
+// queue is unbounded, since dropping an eviction would break the callback's
+// exactly-once promise; a callback that falls behind costs memory instead.
+type evictionFeed struct {
+	callback func(store.Eviction)
+
+	mu      sync.Mutex
+	pending []store.Eviction
+	ready   chan struct{} // signalled when evictions are queued
+}
+
+func newEvictionFeed(callback func(store.Eviction)) *evictionFeed {
+	return &evictionFeed{callback: callback, ready: make(chan struct{}, 1)}
+}
+
+// add queues an eviction. It is the store's EvictionHook.
+func (f *evictionFeed) add(ev store.Eviction) {
+	f.mu.Lock()
+	f.pending = append(f.pending, ev)
+	f.mu.Unlock()
+	select {
+	case f.ready <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers queued evictions until ctx is done, then delivers whatever is
+// left so none are lost on Stop.
+func (f *evictionFeed) run(ctx context.Context) {
+	for {
+		f.deliver()
+		select {
+		case <-f.ready:
+		case <-ctx.Done():
+			f.deliver()
+			return
+		}
+	}
+}
+
+// deliver hands every queued eviction to the callback.
+func (f *evictionFeed) deliver() {
+	for {
+		f.mu.Lock()
+		batch := f.pending
+		f.pending = nil
+		f.mu.Unlock()
+		if len(batch) == 0 {
+			return
+		}
+		for _, ev := range batch {
+			f.callback(ev)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	pb "distributed-kv-store/kvstore"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TimedOutNodesMetadataKey is the trailer in which Scan lists the nodes it
+// gave up on when the fan-out budget ran out.
+const TimedOutNodesMetadataKey = "x-timed-out-nodes"
+
+// fanOutReserve is the share of a fan-out's time held back from the calls to
+// individual nodes, so that whatever completed can still be merged and sent
+// before the caller's own deadline passes.
+const fanOutReserve = 10
+
+// fanOutContext returns the context the calls of a cluster-wide fan-out
+// share. Its deadline is FanOutTimeout from now, or the caller's deadline if
+// that comes first, less fanOutReserve percent of the time left. Every node
+// gets the same deadline however many there are, so the operation as a
+// whole stays bounded. Without either a timeout or a caller deadline the
+// calls run unbounded, as before.
+func (s *Server) fanOutContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if s.fanOutTimeout > 0 {
+		if budget := time.Now().Add(s.fanOutTimeout); !ok || budget.Before(deadline) {
+			deadline, ok = budget, true
+		}
+	}
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	remaining := time.Until(deadline)
+	return context.WithDeadline(ctx, deadline.Add(-remaining*fanOutReserve/100))
+}
+
+// timedOut reports whether a node's part of a fan-out failed because the
+// fan-out budget ran out while the caller was still waiting.
+func timedOut(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return errors.Is(err, context.DeadlineExceeded) || status.Code(err) == codes.DeadlineExceeded
+}
+
+// nodeError describes a node's failure in a fan-out.
+func nodeError(ctx context.Context, node string, err error) *pb.NodeError {
+	return &pb.NodeError{Node: node, Error: err.Error(), TimedOut: timedOut(ctx, err)}
+}
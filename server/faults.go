@@ -0,0 +1,89 @@
+//go:build faultinject
+
+package server
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Faults describes failures to inject into a node's request handling. It is
+// only available in binaries built with the faultinject build tag.
+type Faults struct {
+	Methods  []string      // RPC names such as "Get" to affect; empty matches all
+	Keys     []string      // keys to affect; empty matches all
+	Latency  time.Duration // delay added before a matching request is handled
+	DropRate float64       // fraction of matching requests failed with Unavailable
+	Error    codes.Code    // if not OK, every matching request fails with this code
+	Seed     int64         // seed for DropRate so runs are reproducible
+}
+
+type faultInjector struct {
+	mu     sync.Mutex
+	faults *Faults
+	rand   *rand.Rand
+}
+
+// InjectFaults replaces the faults injected into this node's requests.
+func (n *Node) InjectFaults(f Faults) {
+	n.faults.mu.Lock()
+	defer n.faults.mu.Unlock()
+	n.faults.faults = &f
+	n.faults.rand = rand.New(rand.NewSource(f.Seed))
+}
+
+// ClearFaults stops injecting faults.
+func (n *Node) ClearFaults() {
+	n.faults.mu.Lock()
+	defer n.faults.mu.Unlock()
+	n.faults.faults = nil
+}
+
+// decide returns the faults that apply to a request and whether it should be dropped.
+func (fi *faultInjector) decide(method string, req interface{}) (*Faults, bool) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	f := fi.faults
+	if f == nil {
+		return nil, false
+	}
+	if len(f.Methods) > 0 && !contains(f.Methods, method) {
+		return nil, false
+	}
+	if len(f.Keys) > 0 {
+		keyed, ok := req.(interface{ GetKey() string })
+		if !ok || !contains(f.Keys, keyed.GetKey()) {
+			return nil, false
+		}
+	}
+	return f, f.DropRate > 0 && fi.rand.Float64() < f.DropRate
+}
+
+func (fi *faultInjector) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	method := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]
+	f, drop := fi.decide(method, req)
+	if f == nil {
+		return handler(ctx, req)
+	}
+	if f.Latency > 0 {
+		select {
+		case <-time.After(f.Latency):
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	if drop {
+		return nil, status.Error(codes.Unavailable, "fault injection: request dropped")
+	}
+	if f.Error != codes.OK {
+		return nil, status.Errorf(f.Error, "fault injection: %s", method)
+	}
+	return handler(ctx, req)
+}
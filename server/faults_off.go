@@ -0,0 +1,16 @@
+//go:build !faultinject
+
+package server
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// faultInjector is a no-op unless the binary is built with the faultinject tag.
+type faultInjector struct{}
+
+func (*faultInjector) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(ctx, req)
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"distributed-kv-store/hash"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// ClusterSecretMetadataKey carries Config.ClusterSecret on every call one
+// node makes to another, proving the caller is a member of the cluster.
+const ClusterSecretMetadataKey = "x-cluster-secret"
+
+// clusterCredentials attaches the cluster secret to outgoing calls.
+type clusterCredentials string
+
+func (c clusterCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{ClusterSecretMetadataKey: string(c)}, nil
+}
+
+// RequireTransportSecurity is false because nodes talk to each other in
+// plaintext; the secret is only as private as the network between them.
+func (clusterCredentials) RequireTransportSecurity() bool { return false }
+
+// peerDialOptions returns the options nodes dial each other with.
+func peerDialOptions(secret string) []grpc.DialOption {
+	if secret == "" {
+		return nil
+	}
+	return []grpc.DialOption{grpc.WithPerRPCCredentials(clusterCredentials(secret))}
+}
+
+// hasClusterSecret reports whether the incoming request carries secret.
+func hasClusterSecret(ctx context.Context, secret string) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	sent := md.Get(ClusterSecretMetadataKey)
+	return len(sent) == 1 && subtle.ConstantTimeCompare([]byte(sent[0]), []byte(secret)) == 1
+}
+
+// forwardTrust decides whether to believe a request's ForwardedMetadataKey.
+// A forwarded request skips routing, client admission and client request
+// counts, so the header is only honoured when it names a ring member and
+// the caller proves it is that member: by sending the cluster secret if
+// one is configured, and otherwise by connecting from the member's host.
+// Any other request has the header removed and is treated as a client's.
+type forwardTrust struct {
+	ring   *hash.HashRing
+	secret string
+	hosts  hostCache
+}
+
+func (f *forwardTrust) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if node := forwardedBy(ctx); node != "" && !f.trusted(ctx, node) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		md = md.Copy()
+		md.Delete(ForwardedMetadataKey)
+		ctx = metadata.NewIncomingContext(ctx, md)
+	}
+	return handler(ctx, req)
+}
+
+func (f *forwardTrust) trusted(ctx context.Context, node string) bool {
+	if !f.ring.HasNode(node) {
+		return false
+	}
+	if f.secret != "" {
+		return hasClusterSecret(ctx, f.secret)
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	if path, unix := strings.CutPrefix(node, "unix:"); unix {
+		// Only processes on this machine can reach a Unix socket.
+		return path != "" && p.Addr.Network() == "unix"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return false
+	}
+	return f.hosts.matches(node, net.ParseIP(host))
+}
+
+// hostCacheTTL is how long the resolved addresses of a member's host name
+// are reused before it is looked up again.
+const hostCacheTTL = time.Minute
+
+// hostCache resolves the host of a member address to its IP addresses,
+// caching the result so a forwarded request does not wait on DNS.
+type hostCache struct {
+	resolve func(host string) ([]net.IP, error) // nil uses net.LookupIP
+
+	mu      sync.Mutex
+	entries map[string]hostEntry
+}
+
+type hostEntry struct {
+	ips      []net.IP
+	resolved time.Time
+}
+
+// matches reports whether ip is one of the addresses of node's host. Any
+// loopback address matches a loopback host, so a node listening on
+// localhost is recognized whichever loopback address it dials from.
+func (c *hostCache) matches(node string, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	host, _, err := net.SplitHostPort(node)
+	if err != nil {
+		return false
+	}
+	for _, addr := range c.lookup(host) {
+		if addr.Equal(ip) || addr.IsLoopback() && ip.IsLoopback() {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *hostCache) lookup(host string) []net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}
+	}
+	c.mu.Lock()
+	e, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && time.Since(e.resolved) < hostCacheTTL {
+		return e.ips
+	}
+
+	// Resolve without the lock, so a slow lookup only holds up requests
+	// claiming to come from this host. Concurrent misses may each resolve it.
+	resolve := c.resolve
+	if resolve == nil {
+		resolve = net.LookupIP
+	}
+	ips, err := resolve(host)
+	if err != nil {
+		ips = nil // retried once the entry expires
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]hostEntry)
+	}
+	c.entries[host] = hostEntry{ips: ips, resolved: time.Now()}
+	return ips
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"distributed-kv-store/hash"
+	pb "distributed-kv-store/kvstore"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// foreignKey returns a key that node does not own.
+func foreignKey(t testing.TB, node *Node) string {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if node.server.primary(key) != node.Address() {
+			return key
+		}
+	}
+	t.Fatal("node owns every key")
+	return ""
+}
+
+func TestForwardedHeaderFromClientIgnored(t *testing.T) {
+	for _, secret := range []string{"", "s3cret"} {
+		t.Run(fmt.Sprintf("secret=%q", secret), func(t *testing.T) {
+			nodes := startCluster(t, 3, func(cfg *Config) { cfg.ClusterSecret = secret })
+			client := dial(t, nodes[0])
+			key := foreignKey(t, nodes[0])
+
+			// Claiming to be forwarded, by a stranger and, with a secret, by
+			// a member, must not make node 0 serve a key it does not own.
+			forgers := []string{"10.9.9.9:50051"}
+			if secret != "" {
+				forgers = append(forgers, nodes[1].Address())
+			}
+			for _, forger := range forgers {
+				ctx := metadata.AppendToOutgoingContext(context.Background(), ForwardedMetadataKey, forger)
+				if _, err := client.Put(ctx, &pb.PutRequest{Key: key, Value: forger}); err != nil {
+					t.Fatalf("Put claiming to be forwarded by %s: %v", forger, err)
+				}
+				resp, err := client.Get(context.Background(), &pb.GetRequest{Key: key})
+				if err != nil || resp.Value != forger {
+					t.Fatalf("Get(%s) = %v, %v; the Put was not routed to the owner", key, resp, err)
+				}
+				if _, found := nodes[0].Store().Get(key); found {
+					t.Fatalf("node 0 stored %s although it does not own it", key)
+				}
+			}
+			if ops := nodes[0].server.ops.snapshot(); ops["Put"] != uint64(len(forgers)) {
+				t.Fatalf("node 0 counted %d client Puts, want %d", ops["Put"], len(forgers))
+			}
+		})
+	}
+}
+
+func TestForwardTrust(t *testing.T) {
+	ring := hash.NewHashRing(1)
+	ring.AddNode("127.0.0.1:50051")
+	ring.AddNode("10.0.0.2:50051")
+	ring.AddNode("unix:///tmp/kv.sock")
+	from := func(addr net.Addr) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: addr})
+	}
+	tcp := func(ip string) net.Addr { return &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000} }
+
+	f := &forwardTrust{ring: ring}
+	tests := []struct {
+		name string
+		ctx  context.Context
+		node string
+		want bool
+	}{
+		{"member from its host", from(tcp("10.0.0.2")), "10.0.0.2:50051", true},
+		{"member from another host", from(tcp("10.0.0.3")), "10.0.0.2:50051", false},
+		{"loopback member from loopback", from(tcp("::1")), "127.0.0.1:50051", true},
+		{"not a member", from(tcp("10.0.0.9")), "10.0.0.9:50051", false},
+		{"unix member over a socket", from(&net.UnixAddr{Net: "unix"}), "unix:///tmp/kv.sock", true},
+		{"unix member over tcp", from(tcp("127.0.0.1")), "unix:///tmp/kv.sock", false},
+		{"no peer", context.Background(), "10.0.0.2:50051", false},
+	}
+	for _, tt := range tests {
+		if got := f.trusted(tt.ctx, tt.node); got != tt.want {
+			t.Errorf("%s: trusted = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	withSecret := &forwardTrust{ring: ring, secret: "s3cret"}
+	ctx := from(tcp("10.0.0.2"))
+	if withSecret.trusted(ctx, "10.0.0.2:50051") {
+		t.Error("member without the cluster secret trusted")
+	}
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(ClusterSecretMetadataKey, "s3cret"))
+	if !withSecret.trusted(ctx, "10.0.0.2:50051") {
+		t.Error("member with the cluster secret not trusted")
+	}
+}
+
+// TestHostCacheResolvesWithoutLock holds one host's lookup and checks that a
+// host already cached is still matched meanwhile.
+func TestHostCacheResolvesWithoutLock(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	c := &hostCache{resolve: func(host string) ([]net.IP, error) {
+		if host == "slow.example" {
+			close(entered)
+			<-release
+		}
+		return []net.IP{net.ParseIP("10.0.0.2")}, nil
+	}}
+	if !c.matches("fast.example:50051", net.ParseIP("10.0.0.2")) {
+		t.Fatal("fast.example not matched")
+	}
+
+	slow := make(chan bool)
+	go func() { slow <- c.matches("slow.example:50051", net.ParseIP("10.0.0.2")) }()
+	<-entered
+	matched := make(chan bool)
+	go func() { matched <- c.matches("fast.example:50051", net.ParseIP("10.0.0.2")) }()
+	select {
+	case ok := <-matched:
+		if !ok {
+			t.Fatal("cached fast.example not matched")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a slow lookup blocked a cached host")
+	}
+	close(release)
+	if !<-slow {
+		t.Fatal("slow.example not matched once resolved")
+	}
+}
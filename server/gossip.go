@@ -0,0 +1,295 @@
+package server
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"distributed-kv-store/hash"
+	pb "distributed-kv-store/kvstore"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// membership tracks cluster members with heartbeat gossip. Every round a node
+// bumps its own heartbeat and exchanges its live view with one random peer;
+// both sides keep the highest heartbeat seen per member. A member whose
+// heartbeat has not advanced within failureTimeout is considered failed and
+// removed from the ring, and is added back if a newer heartbeat shows up.
+// Heartbeats start from the clock, so a restarted node's are newer than any
+// its peers remember. A node that leaves gossips a final heartbeat marked
+// left, which removes it from the ring without waiting for the timeout.
+type membership struct {
+	mu             sync.Mutex
+	self           string
+	heartbeat      uint64
+	left           bool
+	members        map[string]*member
+	seeds          []string
+	ring           *hash.HashRing
+	metadata       map[string]hash.Metadata // known node metadata, reapplied when a node rejoins
+	pins           *pinTable                // exchanged alongside membership
+	failureTimeout time.Duration
+}
+
+type member struct {
+	heartbeat uint64
+	updated   time.Time
+	alive     bool
+	left      bool
+}
+
+func newMembership(self string, nodes, seeds []string, ring *hash.HashRing, metadata map[string]hash.Metadata, pins *pinTable, failureTimeout time.Duration) *membership {
+	m := &membership{
+		self:           self,
+		heartbeat:      uint64(time.Now().UnixMilli()),
+		members:        make(map[string]*member),
+		seeds:          seeds,
+		ring:           ring,
+		metadata:       metadata,
+		pins:           pins,
+		failureTimeout: failureTimeout,
+	}
+	now := time.Now()
+	for _, node := range nodes {
+		if node != self {
+			m.members[node] = &member{updated: now, alive: true}
+		}
+	}
+	return m
+}
+
+// view returns the members this node believes alive, including itself, and
+// those it knows have left, so that departures spread like joins.
+func (m *membership) view() []*pb.Member {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	view := []*pb.Member{{Address: m.self, Heartbeat: m.heartbeat, Left: m.left}}
+	for addr, mem := range m.members {
+		if mem.alive || mem.left {
+			view = append(view, &pb.Member{Address: addr, Heartbeat: mem.heartbeat, Left: mem.left})
+		}
+	}
+	return view
+}
+
+// merge folds a peer's view into ours, adding newly seen or recovered members
+// to the ring and removing those that left.
+func (m *membership) merge(remote []*pb.Member) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, r := range remote {
+		if r.Address == m.self || r.Address == "" {
+			continue
+		}
+		mem, ok := m.members[r.Address]
+		if ok && r.Heartbeat <= mem.heartbeat {
+			continue
+		}
+		if !ok && r.Left {
+			// Departures of members we never knew or already forgot are
+			// not recorded, so they stop echoing between peers.
+			if m.ring.HasNode(r.Address) {
+				m.ring.RemoveNode(r.Address)
+				log.Printf("Node %s left", r.Address)
+			}
+			continue
+		}
+		if !ok {
+			mem = &member{}
+			m.members[r.Address] = mem
+		}
+		mem.heartbeat = r.Heartbeat
+		mem.updated = now
+		mem.left = r.Left
+		if r.Left {
+			if mem.alive {
+				mem.alive = false
+				m.ring.RemoveNode(r.Address)
+				log.Printf("Node %s left", r.Address)
+			}
+			continue
+		}
+		if !mem.alive {
+			mem.alive = true
+			m.ring.AddNodeWithMetadata(r.Address, m.metadata[r.Address])
+			log.Printf("Node %s joined", r.Address)
+		}
+	}
+}
+
+// detectFailures removes members whose heartbeat has gone stale from the ring
+// and forgets those that are gone for good. A member that left is announced
+// for failureTimeout, long enough for every peer to have either heard of it
+// or timed the member out, so none still gossips it as alive; a failed one is
+// kept as long again, in case its heartbeat resumes.
+func (m *membership) detectFailures() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for addr, mem := range m.members {
+		silent := now.Sub(mem.updated)
+		switch {
+		case mem.alive && silent > m.failureTimeout:
+			mem.alive = false
+			m.ring.RemoveNode(addr)
+			log.Printf("Node %s failed", addr)
+		case mem.left && silent > m.failureTimeout, !mem.alive && silent > 2*m.failureTimeout:
+			delete(m.members, addr)
+		}
+	}
+}
+
+// nextPeer picks a random live member to gossip with, falling back to a seed
+// while we know of no one else.
+func (m *membership) nextPeer() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	alive := []string{}
+	for addr, mem := range m.members {
+		if mem.alive {
+			alive = append(alive, addr)
+		}
+	}
+	if len(alive) == 0 {
+		alive = m.seeds
+	}
+	if len(alive) == 0 {
+		return ""
+	}
+	return alive[rand.Intn(len(alive))]
+}
+
+// round runs one gossip exchange.
+func (m *membership) round(ctx context.Context, peers *connPool) {
+	m.mu.Lock()
+	m.heartbeat++
+	m.mu.Unlock()
+
+	m.detectFailures()
+
+	peer := m.nextPeer()
+	if peer == "" || peer == m.self {
+		return
+	}
+	m.exchange(ctx, peers, peer)
+}
+
+// exchange swaps membership views and key pins with one peer.
+func (m *membership) exchange(ctx context.Context, peers *connPool, peer string) error {
+	client, err := peers.client(peer)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Gossip(ctx, &pb.GossipRequest{Members: m.view(), Pins: m.pins.proto()})
+	if err != nil {
+		return err
+	}
+	m.merge(resp.Members)
+	m.pins.merge(resp.Pins)
+	return nil
+}
+
+// leave marks this node as leaving with a final heartbeat and returns the
+// members to announce it to.
+func (m *membership) leave() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.heartbeat++
+	m.left = true
+	var alive []string
+	for addr, mem := range m.members {
+		if mem.alive {
+			alive = append(alive, addr)
+		}
+	}
+	return alive
+}
+
+// remember records metadata for a node unless some is already known, so it
+// is applied if the node joins.
+func (m *membership) remember(node string, meta hash.Metadata) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(meta) == 0 {
+		return
+	}
+	if m.metadata == nil {
+		m.metadata = make(map[string]hash.Metadata)
+	}
+	if _, ok := m.metadata[node]; !ok {
+		m.metadata[node] = meta
+	}
+}
+
+// authorizeGossip admits a gossip exchange from callers that prove they are
+// a member, with the cluster secret, or an operator, with the admin token.
+// Anyone else could add nodes to the ring or announce that members left.
+func (s *Server) authorizeGossip(ctx context.Context) error {
+	if s.clusterSecret == "" && s.adminToken == "" {
+		return status.Error(codes.FailedPrecondition, "gossip requires a cluster secret or admin token")
+	}
+	if s.clusterSecret != "" && hasClusterSecret(ctx, s.clusterSecret) {
+		return nil
+	}
+	if s.adminToken != "" && s.authorizeAdmin(ctx) == nil {
+		return nil
+	}
+	return status.Error(codes.Unauthenticated, "gossip requires the cluster secret or admin token")
+}
+
+// Gossip merges a peer's membership view and key pins and replies with ours.
+func (s *Server) Gossip(ctx context.Context, req *pb.GossipRequest) (*pb.GossipResponse, error) {
+	if s.members == nil {
+		return nil, status.Error(codes.FailedPrecondition, "gossip is not enabled on this node")
+	}
+	if err := s.authorizeGossip(ctx); err != nil {
+		return nil, err
+	}
+	s.members.merge(req.Members)
+	s.pins.merge(req.Pins)
+	return &pb.GossipResponse{Members: s.members.view(), Pins: s.pins.proto()}, nil
+}
+
+// gossip runs one membership round, bounded by the gossip interval.
+func (n *Node) gossip(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, n.config.GossipInterval)
+	defer cancel()
+	n.server.members.round(ctx, n.server.peers)
+}
+
+// leave takes this node out of the cluster before it stops serving. It
+// gossips its departure to every live member directly and waits, up to
+// LeaveTimeout, for them to acknowledge it. Its own ring is left as it was,
+// so requests a peer routed here just before it learned are still served.
+// Members it cannot reach learn of it through gossip, or drop the node once
+// its heartbeat goes stale. Keys are not handed off; the new owners start
+// without them unless they share a cold tier.
+func (n *Node) leave() {
+	peers := n.server.members.leave()
+
+	ctx, cancel := context.WithTimeout(context.Background(), n.config.LeaveTimeout)
+	defer cancel()
+	var (
+		wg    sync.WaitGroup
+		acked atomic.Int32
+	)
+	for _, peer := range peers {
+		wg.Add(1)
+		go func(peer string) {
+			defer wg.Done()
+			if err := n.server.members.exchange(ctx, n.server.peers, peer); err != nil {
+				log.Printf("Failed to announce departure to %s: %v", peer, err)
+				return
+			}
+			acked.Add(1)
+		}(peer)
+	}
+	wg.Wait()
+	log.Printf("Left the cluster; %d of %d members acknowledged", acked.Load(), len(peers))
+}
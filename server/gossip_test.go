@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"distributed-kv-store/hash"
+	pb "distributed-kv-store/kvstore"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGossipRequiresCredentials(t *testing.T) {
+	nodes := startCluster(t, 2, func(cfg *Config) {
+		cfg.GossipInterval = time.Hour // exchanges are made by the test
+		cfg.ClusterSecret = "s3cret"
+		cfg.AdminToken = "admin"
+	})
+	client := dial(t, nodes[0])
+	// A stranger announcing that node 1 left would take it out of the ring.
+	req := &pb.GossipRequest{Members: []*pb.Member{{Address: nodes[1].Address(), Heartbeat: 1 << 62, Left: true}}}
+
+	tests := []struct {
+		name string
+		md   []string
+		want codes.Code
+	}{
+		{"no credentials", nil, codes.Unauthenticated},
+		{"wrong secret", []string{ClusterSecretMetadataKey, "guess"}, codes.Unauthenticated},
+		{"wrong admin token", []string{AdminTokenMetadataKey, "guess"}, codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), tt.md...)
+		if _, err := client.Gossip(ctx, req); status.Code(err) != tt.want {
+			t.Errorf("%s: Gossip error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if !nodes[0].server.hashRing.HasNode(nodes[1].Address()) {
+		t.Fatal("unauthenticated gossip removed a member from the ring")
+	}
+
+	// Members send the secret on their own, and operators can use the admin token.
+	if err := nodes[1].server.members.exchange(context.Background(), nodes[1].server.peers, nodes[0].Address()); err != nil {
+		t.Fatalf("gossip between members: %v", err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), AdminTokenMetadataKey, "admin")
+	if _, err := client.Gossip(ctx, &pb.GossipRequest{}); err != nil {
+		t.Fatalf("Gossip with the admin token: %v", err)
+	}
+}
+
+func TestGossipRefusedWithoutCredentialsConfigured(t *testing.T) {
+	nodes := startCluster(t, 1, func(cfg *Config) { cfg.GossipInterval = time.Hour })
+	if _, err := dial(t, nodes[0]).Gossip(context.Background(), &pb.GossipRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("Gossip error = %v, want FailedPrecondition", err)
+	}
+}
+
+func TestMembershipForgetsDepartedMembers(t *testing.T) {
+	ring := hash.NewHashRing(1)
+	for _, node := range []string{"a:1", "b:1", "c:1"} {
+		ring.AddNode(node)
+	}
+	m := newMembership("a:1", []string{"a:1", "b:1", "c:1"}, nil, ring, nil, newPinTable(0, nil), time.Minute)
+
+	m.merge([]*pb.Member{{Address: "b:1", Heartbeat: 1, Left: true}})
+	if ring.HasNode("b:1") {
+		t.Fatal("member that left is still in the ring")
+	}
+	m.detectFailures()
+	if _, ok := m.members["b:1"]; !ok {
+		t.Fatal("departure forgotten before it could spread")
+	}
+
+	// Age b's departure and c's failure past their retention.
+	m.members["b:1"].updated = time.Now().Add(-2 * time.Minute)
+	m.members["c:1"].updated = time.Now().Add(-2 * time.Minute)
+	m.detectFailures()
+	if _, ok := m.members["b:1"]; ok {
+		t.Fatal("member that left was not forgotten")
+	}
+	if ring.HasNode("c:1") {
+		t.Fatal("failed member is still in the ring")
+	}
+	m.members["c:1"].updated = time.Now().Add(-3 * time.Minute)
+	m.detectFailures()
+	if len(m.members) != 0 {
+		t.Fatalf("members = %v, want none", m.members)
+	}
+
+	// A peer that is slower to forget must not bring the departure back.
+	m.merge([]*pb.Member{{Address: "b:1", Heartbeat: 1, Left: true}})
+	if len(m.members) != 0 {
+		t.Fatalf("forgotten departure recorded again: %v", m.members)
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"sort"
+
+	pb "distributed-kv-store/kvstore"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FindByIndex returns the keys whose values produce the given secondary
+// index term. Unless the request is local, every node in the ring is asked
+// and the results are merged.
+func (s *Server) FindByIndex(ctx context.Context, req *pb.FindByIndexRequest) (*pb.FindByIndexResponse, error) {
+	if !s.indexed {
+		return nil, status.Error(codes.FailedPrecondition, "secondary index is not configured on this node")
+	}
+	if req.Local {
+		return &pb.FindByIndexResponse{Keys: s.store.FindByIndex(req.Term)}, nil
+	}
+
+	keys := []string{}
+	for _, node := range s.hashRing.Nodes() {
+		if node == s.currentNode {
+			keys = append(keys, s.store.FindByIndex(req.Term)...)
+			continue
+		}
+		client, err := s.peer(node)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		resp, err := client.FindByIndex(ctx, &pb.FindByIndexRequest{Term: req.Term, Local: true})
+		if err != nil {
+			return nil, toStatus(s.forwardErr(node, err))
+		}
+		s.recordHealth(node, nil)
+		keys = append(keys, resp.Keys...)
+	}
+	sort.Strings(keys)
+	return &pb.FindByIndexResponse{Keys: keys}, nil
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"context"
+
+	pb "distributed-kv-store/kvstore"
+	"distributed-kv-store/store"
+
+	"google.golang.org/grpc"
+)
+
+// rejectEmptyKeys fails requests that name an empty key with
+// store.ErrEmptyKey (InvalidArgument) before they are routed. An empty key
+// would otherwise hash to some node like any other, and cannot be kept in a
+// cold tier.
+func rejectEmptyKeys(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	empty := false
+	switch req := req.(type) {
+	case *pb.BatchDeleteRequest:
+		for _, key := range req.Keys {
+			empty = empty || key == ""
+		}
+	case *pb.RenameRequest:
+		empty = req.Key == "" || req.NewKey == ""
+	case interface{ GetKey() string }:
+		empty = req.GetKey() == ""
+	}
+	if empty {
+		return nil, toStatus(store.ErrEmptyKey)
+	}
+	return handler(ctx, req)
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	pb "distributed-kv-store/kvstore"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestEmptyKeyRejected(t *testing.T) {
+	nodes := startCluster(t, 1, nil)
+	client := dial(t, nodes[0])
+	ctx := context.Background()
+
+	calls := map[string]func() error{
+		"Put": func() error {
+			_, err := client.Put(ctx, &pb.PutRequest{Key: "", Value: "v"})
+			return err
+		},
+		"Get": func() error {
+			_, err := client.Get(ctx, &pb.GetRequest{Key: ""})
+			return err
+		},
+		"Delete": func() error {
+			_, err := client.Delete(ctx, &pb.DeleteRequest{Key: ""})
+			return err
+		},
+		"BatchDelete": func() error {
+			_, err := client.BatchDelete(ctx, &pb.BatchDeleteRequest{Keys: []string{"a", ""}})
+			return err
+		},
+		"Rename": func() error {
+			_, err := client.Rename(ctx, &pb.RenameRequest{Key: "a", NewKey: ""})
+			return err
+		},
+	}
+	for name, call := range calls {
+		if code := status.Code(call()); code != codes.InvalidArgument {
+			t.Errorf("%s with an empty key: got %v, want InvalidArgument", name, code)
+		}
+	}
+}
+
+func TestEmptyKeyAllowed(t *testing.T) {
+	nodes := startCluster(t, 1, func(cfg *Config) { cfg.AllowEmptyKeys = true })
+	client := dial(t, nodes[0])
+	ctx := context.Background()
+
+	if _, err := client.Put(ctx, &pb.PutRequest{Key: "", Value: "v"}); err != nil {
+		t.Fatalf("Put with an empty key: %v", err)
+	}
+	resp, err := client.Get(ctx, &pb.GetRequest{Key: ""})
+	if err != nil {
+		t.Fatalf("Get with an empty key: %v", err)
+	}
+	if !resp.Found || resp.Value != "v" {
+		t.Fatalf("Get(\"\") = %q, %v; want v, true", resp.Value, resp.Found)
+	}
+	if _, err := client.Delete(ctx, &pb.DeleteRequest{Key: ""}); err != nil {
+		t.Fatalf("Delete with an empty key: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// lifecycle tracks a node's background goroutines under one context so that
+// stopping the node cancels them all and waits for them to exit.
+type lifecycle struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newLifecycle() *lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &lifecycle{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in a tracked goroutine. fn must return once ctx is done.
+func (l *lifecycle) Go(fn func(ctx context.Context)) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		fn(l.ctx)
+	}()
+}
+
+// Every runs fn every interval in a tracked goroutine until the lifecycle stops.
+func (l *lifecycle) Every(interval time.Duration, fn func(ctx context.Context)) {
+	l.Go(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fn(ctx)
+			}
+		}
+	})
+}
+
+// Stop cancels every tracked goroutine and waits for them to return.
+func (l *lifecycle) Stop() {
+	l.cancel()
+	l.wg.Wait()
+}
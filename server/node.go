@@ -0,0 +1,470 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"distributed-kv-store/hash"
+	pb "distributed-kv-store/kvstore"
+	"distributed-kv-store/store"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+// Config describes a single node of the cluster.
+type Config struct {
+	Address             string                   // address this node listens on and is known by in the ring; host:port or unix:///path
+	Nodes               []string                 // every node in the cluster, including Address
+	NodeMetadata        map[string]hash.Metadata // optional metadata (zone, rack, ...) per node address
+	VirtualNodes        int                      // virtual nodes per physical node on the hash ring
+	MaxVirtualNodes     int                      // cap on virtual nodes across the ring, scaling VirtualNodes down in large clusters (0 means no cap)
+	PlacementSalt       string                   // if set, keys are placed by a salted SHA-256 of the key (must match on every node)
+	ReplicationFactor   int                      // number of nodes responsible for each key
+	RequireReplicas     bool                     // reject writes while there are fewer nodes than ReplicationFactor instead of warning and running with fewer replicas
+	ReplicaStrategy     hash.ReplicaStrategy     // how replicas are placed (defaults to hash.NextN)
+	ResponseCacheTTL    time.Duration            // cache Get responses for keys owned by other nodes this long; cached reads may be stale by up to this much (0 disables)
+	Redirect            bool                     // answer requests for other nodes' keys with a redirect instead of forwarding them
+	Reflection          bool                     // register the gRPC reflection service so tools like grpcurl can discover the API
+	RequestTimeout      time.Duration            // default deadline for requests without one (0 disables)
+	FanOutTimeout       time.Duration            // overall budget for Scan, ClusterStats, Flush and BatchDelete across all nodes (0 uses the request deadline only)
+	MaxValueSize        int                      // maximum value size in bytes (0 means unlimited)
+	AllowEmptyKeys      bool                     // accept "" as a key instead of rejecting it with InvalidArgument
+	CapacityHint        int                      // number of keys to size the in-memory store for up front; not a limit
+	SoftDeleteRetention time.Duration            // keep deleted keys restorable with Undelete for this long (0 deletes immediately)
+	DeleteGrace         time.Duration            // report deleted keys as recently_deleted in Get for this long (0 disables)
+	SkipExpiredWrites   bool                     // drop writes whose absolute expiry has passed instead of rejecting them
+	TTLJitter           float64                  // expire fixed TTLs up to this fraction early, at random, to stagger expiry (0 disables)
+	StaleWindow         time.Duration            // keep serving expired keys this long while Loader refreshes them (0 disables)
+	Loader              store.Loader             // optional source of fresh values for stale keys
+	WriteTransform      store.WriteTransform     // optional hook that rewrites or rejects values on write
+	NamespaceQuotas     map[string]int           // most keys each key prefix may hold on each node
+	CompactionThreshold float64                  // rebuild the in-memory map when overwrites and deletes exceed this multiple of its size (0 disables)
+	SweepInterval       time.Duration            // how often expired keys are reclaimed (0 disables)
+	PeerIdleTimeout     time.Duration            // close peer connections unused for this long (0 keeps them open)
+	ColdStore           store.ColdStore          // optional slower tier behind the in-memory store
+	Index               store.IndexFunc          // optional secondary index term extractor, enables FindByIndex
+	Engine              store.StorageEngine      // storage to serve from; defaults to an in-memory store built from the fields above
+
+	// Admission control. At most MaxConcurrentRequests handlers run at once
+	// (0 disables the limit); a request waits up to AdmissionWait for a slot.
+	// Waiting requests are admitted by priority (see PriorityMetadataKey).
+	// Requests from other nodes have a separate pool of the same size.
+	MaxConcurrentRequests int
+	AdmissionWait         time.Duration
+
+	// Circuit breaking. After BreakerThreshold consecutive failures to reach a
+	// peer, calls to it fail fast for BreakerCooldown (0 threshold disables).
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// Peer connection pool. Forwarded calls share one connection per peer
+	// unless PeerMaxConns is raised, in which case another connection is
+	// dialed once every open one carries PeerConnStreams calls (default 32).
+	// Idle connections are closed down to PeerMinConns per peer.
+	PeerMinConns    int
+	PeerMaxConns    int
+	PeerConnStreams int
+
+	// Admin RPCs (Dump, Flush) are disabled unless AdminToken is set. Dump
+	// runs at most once per DumpInterval.
+	AdminToken   string
+	DumpInterval time.Duration
+
+	// Cluster membership proof. Requests forwarded by another node skip
+	// routing and client admission, so a node only believes the forwarding
+	// header from a ring member. When ClusterSecret is set, which must be
+	// the same on every node, members prove themselves by sending it on
+	// every call to each other; otherwise a forwarded request must come from
+	// the member's host, which anyone else on that host can also do.
+	ClusterSecret string
+
+	// Latency SLO. When SLOLatency is set, the p99 latency of requests over
+	// the last SLOWindow (default one minute) is checked against it and
+	// reported in Stats. OnSLOChange, if set, is called from a background
+	// goroutine each time the SLO is breached or recovers.
+	SLOLatency  time.Duration
+	SLOWindow   time.Duration
+	OnSLOChange func(SLOStatus)
+
+	// Access control. When ACL is non-empty, key data RPCs are only allowed
+	// for identities holding a matching rule (see ACLRule and LoadACL).
+	// Nodes identify as their own address when they Bootstrap, so grant them
+	// read access if both are used. The identity is the client-supplied
+	// CallerMetadataKey header and is not authenticated, so the ACL guards
+	// against mistakes by cooperating clients, not against an attacker; it
+	// is not a security boundary.
+	ACL []ACLRule
+
+	// Request recording. When RecordPath is set, a RecordSampleRate fraction
+	// (default all) of client data requests is appended to that file for
+	// Replay, until it reaches RecordMaxBytes (0 means unbounded).
+	RecordPath       string
+	RecordSampleRate float64
+	RecordMaxBytes   int64
+
+	// Auditing. Every data access is handed to Auditor from a background
+	// goroutine through a buffer of AuditBuffer events (default 1024).
+	Auditor     Auditor
+	AuditBuffer int
+
+	// Change data capture. Every write committed on this node is handed to
+	// ChangeSink from a background goroutine (see ChangeSink for the
+	// delivery guarantees). While more than ChangeBuffer changes (default
+	// 4096) are waiting, write requests are held back. On Stop, buffered
+	// changes are published for up to ChangeSinkDrain (default 5s).
+	ChangeSink      ChangeSink
+	ChangeBuffer    int
+	ChangeSinkDrain time.Duration
+
+	// Eviction callback. EvictionCallback, if set, is called once for every
+	// entry that expires or is dropped from memory, and for every deleted key
+	// if EvictDeletes is set, with the value it held and the reason. Calls
+	// are made one at a time from a background goroutine, never on the write
+	// path, and evictions still queued on Stop are delivered before it returns.
+	EvictionCallback func(store.Eviction)
+	EvictDeletes     bool
+
+	// Gossip membership. When GossipInterval is set, nodes learn about each
+	// other from Seeds instead of relying on Nodes being complete. Gossip
+	// changes the ring, so it is only accepted from callers sending the
+	// ClusterSecret or AdminToken; without either the RPC is refused.
+	Seeds          []string      // addresses contacted to join the cluster
+	GossipInterval time.Duration // how often membership is exchanged (0 disables gossip)
+	FailureTimeout time.Duration // how long a silent member stays in the ring
+	Bootstrap      bool          // pull the keys this node owns from its peers after joining
+	RingStateFile  string        // save learned membership here and rejoin through it after a restart
+	LeaveTimeout   time.Duration // on Stop, how long to wait for peers to acknowledge this node leaving (0 stops without leaving)
+
+	// Key pinning. Pins routes each listed key to the given node instead of
+	// the node the ring picks, for data locality. Pinned keys bypass the
+	// ring's load balancing, so pin sparingly. A key whose node is not in the
+	// ring is placed by the ring as usual. With gossip, nodes share their
+	// pins and adopt the highest PinsVersion, so bump it when changing pins;
+	// without gossip every node must be given the same pins.
+	Pins        map[string]string
+	PinsVersion uint64
+}
+
+// Node is a self-contained key-value node: its own store, hash ring,
+// connection pool and gRPC server. Several nodes can run in one process.
+type Node struct {
+	config      Config
+	server      *Server
+	grpcServer  *grpc.Server
+	faults      *faultInjector
+	audit       *auditLog         // nil unless auditing is configured
+	changes     *changeFeed       // nil unless a change sink is configured
+	evictions   *evictionFeed     // nil unless an eviction callback is configured
+	replication *replicationGuard // nil unless ReplicationFactor is above one
+	recorder    *requestRecorder  // nil unless request recording is configured
+
+	background *lifecycle
+	stopOnce   sync.Once
+}
+
+// NewNode creates a node from cfg. It does not start listening until Serve is called.
+func NewNode(cfg Config) *Node {
+	if cfg.ReplicationFactor < 1 {
+		cfg.ReplicationFactor = 1
+	}
+	if cfg.ReplicaStrategy == nil {
+		cfg.ReplicaStrategy = hash.NextN{}
+	}
+
+	// Initialize the hash ring and add all nodes.
+	hashRing := hash.NewHashRing(cfg.VirtualNodes)
+	if cfg.MaxVirtualNodes > 0 {
+		hashRing.SetMaxVirtualNodes(cfg.MaxVirtualNodes)
+	}
+	if cfg.PlacementSalt != "" {
+		hashRing.SetKeyDerivation(hash.SaltedKeys(cfg.PlacementSalt))
+	}
+	for _, node := range cfg.Nodes {
+		hashRing.AddNodeWithMetadata(node, cfg.NodeMetadata[node])
+	}
+	if cfg.GossipInterval > 0 && !contains(cfg.Nodes, cfg.Address) {
+		hashRing.AddNodeWithMetadata(cfg.Address, cfg.NodeMetadata[cfg.Address])
+	}
+
+	engine := cfg.Engine
+	if engine == nil {
+		kvs := store.NewKeyValueStoreWithCapacity(cfg.CapacityHint)
+		kvs.SetMaxValueSize(cfg.MaxValueSize)
+		if cfg.ColdStore != nil {
+			kvs.SetColdStore(cfg.ColdStore)
+		}
+		if cfg.Index != nil {
+			kvs.SetIndex(cfg.Index)
+		}
+		kvs.SetSoftDelete(cfg.SoftDeleteRetention)
+		kvs.SetStaleWhileRevalidate(cfg.StaleWindow, cfg.Loader)
+		kvs.SetDeleteGrace(cfg.DeleteGrace)
+		kvs.SetSkipExpiredWrites(cfg.SkipExpiredWrites)
+		kvs.SetTTLJitter(cfg.TTLJitter)
+		kvs.SetWriteTransform(cfg.WriteTransform)
+		kvs.SetQuotas(cfg.NamespaceQuotas)
+		engine = kvs
+	}
+
+	server := &Server{
+		store:       engine,
+		hashRing:    hashRing,
+		currentNode: cfg.Address,
+		nodes:       cfg.Nodes,
+		pins:        newPinTable(cfg.PinsVersion, cfg.Pins),
+		indexed:     cfg.Index != nil,
+		redirect:    cfg.Redirect,
+
+		fanOutTimeout: cfg.FanOutTimeout,
+		cache:         newResponseCache(cfg.ResponseCacheTTL),
+		ops:           newOpCounters(),
+		peers:         newConnPool(cfg.PeerMinConns, cfg.PeerMaxConns, cfg.PeerConnStreams, peerDialOptions(cfg.ClusterSecret)...),
+
+		replicationFactor: cfg.ReplicationFactor,
+		replicaStrategy:   cfg.ReplicaStrategy,
+		unhealthy:         make(map[string]bool),
+		breakers:          newBreakerSet(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		adminToken:        cfg.AdminToken,
+		dumps:             &dumpGate{interval: cfg.DumpInterval},
+		clusterSecret:     cfg.ClusterSecret,
+	}
+	if cfg.GossipInterval > 0 {
+		if cfg.FailureTimeout <= 0 {
+			cfg.FailureTimeout = 10 * cfg.GossipInterval
+		}
+		server.members = newMembership(cfg.Address, cfg.Nodes, cfg.Seeds, hashRing, cfg.NodeMetadata, server.pins, cfg.FailureTimeout)
+	}
+
+	faults := &faultInjector{}
+	interceptors := []grpc.UnaryServerInterceptor{
+		(&forwardTrust{ring: hashRing, secret: cfg.ClusterSecret}).intercept,
+		deadlineInterceptor(cfg.RequestTimeout),
+		server.ops.intercept,
+	}
+	if cfg.SLOLatency > 0 {
+		if cfg.SLOWindow <= 0 {
+			cfg.SLOWindow = time.Minute
+		}
+		server.slo = newSLOTracker(cfg.SLOLatency, cfg.SLOWindow, cfg.OnSLOChange)
+		interceptors = append(interceptors, server.slo.intercept)
+	}
+	if !cfg.AllowEmptyKeys {
+		interceptors = append(interceptors, rejectEmptyKeys)
+	}
+	var audit *auditLog
+	if cfg.Auditor != nil {
+		audit = newAuditLog(cfg.Auditor, cfg.AuditBuffer)
+		interceptors = append(interceptors, audit.intercept)
+	}
+	var changes *changeFeed
+	if notifier, ok := engine.(store.ChangeNotifier); ok && cfg.ChangeSink != nil {
+		changes = newChangeFeed(cfg.ChangeSink, cfg.ChangeBuffer, cfg.ChangeSinkDrain)
+		notifier.SetChangeHook(changes.add)
+		interceptors = append(interceptors, changes.intercept)
+	} else if cfg.ChangeSink != nil {
+		log.Printf("Storage engine cannot report changes, ignoring ChangeSink")
+	}
+	var evictions *evictionFeed
+	if notifier, ok := engine.(store.EvictionNotifier); ok && cfg.EvictionCallback != nil {
+		evictions = newEvictionFeed(cfg.EvictionCallback)
+		notifier.SetEvictionHook(evictions.add, cfg.EvictDeletes)
+	} else if cfg.EvictionCallback != nil {
+		log.Printf("Storage engine cannot report evictions, ignoring EvictionCallback")
+	}
+	var replication *replicationGuard
+	if cfg.ReplicationFactor > 1 {
+		replication = newReplicationGuard(server.hashRing, cfg.ReplicationFactor, cfg.RequireReplicas)
+		interceptors = append(interceptors, replication.intercept)
+	}
+	recorder := newRequestRecorder(cfg.RecordPath, cfg.RecordSampleRate, cfg.RecordMaxBytes)
+	if recorder != nil {
+		interceptors = append(interceptors, recorder.intercept)
+	}
+	var streamInterceptors []grpc.StreamServerInterceptor
+	if acl := newACL(cfg.ACL); acl != nil {
+		interceptors = append(interceptors, acl.intercept)
+		streamInterceptors = append(streamInterceptors, acl.interceptStream)
+	}
+	if cfg.MaxConcurrentRequests > 0 {
+		interceptors = append(interceptors, newAdmission(cfg.MaxConcurrentRequests, cfg.AdmissionWait).intercept)
+	}
+	interceptors = append(interceptors, faults.intercept)
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
+	pb.RegisterKeyValueServiceServer(grpcServer, server)
+	if cfg.Reflection {
+		reflection.Register(grpcServer)
+	}
+
+	return &Node{
+		config:      cfg,
+		server:      server,
+		grpcServer:  grpcServer,
+		faults:      faults,
+		audit:       audit,
+		changes:     changes,
+		evictions:   evictions,
+		replication: replication,
+		recorder:    recorder,
+		background:  newLifecycle(),
+	}
+}
+
+// Address returns the address the node is known by in the ring.
+func (n *Node) Address() string {
+	return n.config.Address
+}
+
+// Store returns the node's local storage engine.
+func (n *Node) Store() store.StorageEngine {
+	return n.server.store
+}
+
+// ListenAndServe listens on the node's address and serves until Stop is called.
+func (n *Node) ListenAndServe() error {
+	listener, err := listen(n.config.Address)
+	if err != nil {
+		return err
+	}
+	return n.Serve(listener)
+}
+
+// listen listens on a TCP address, or on a Unix domain socket for addresses
+// of the form unix:///path (or unix:path), which peers dial the same way. A
+// socket file left behind by a process that is gone is removed first; the
+// listener removes its own file when the node stops.
+func listen(address string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(address, "unix://")
+	if !ok {
+		path, ok = strings.CutPrefix(address, "unix:")
+	}
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("socket %s is in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// Serve serves requests on listener until Stop is called.
+func (n *Node) Serve(listener net.Listener) error {
+	if n.recorder != nil {
+		if err := n.recorder.open(); err != nil {
+			return err
+		}
+	}
+	if n.config.SweepInterval > 0 {
+		n.background.Every(n.config.SweepInterval, n.sweepExpired)
+	}
+	if n.config.PeerIdleTimeout > 0 {
+		n.background.Every(n.config.PeerIdleTimeout/2, n.evictIdlePeers)
+	}
+	if slo := n.server.slo; slo != nil {
+		n.background.Every(n.config.SLOWindow/10, slo.evaluate)
+	}
+	if n.audit != nil {
+		n.background.Go(n.audit.run)
+	}
+	if n.changes != nil {
+		n.background.Go(n.changes.run)
+	}
+	if n.evictions != nil {
+		n.background.Go(n.evictions.run)
+	}
+	if n.replication != nil {
+		n.background.Go(n.replication.watch)
+	}
+	if n.config.Bootstrap {
+		n.background.Go(n.bootstrap)
+	}
+	if n.server.members != nil {
+		if n.config.RingStateFile != "" {
+			n.background.Go(n.syncRingState)
+		}
+		n.background.Every(n.config.GossipInterval, n.gossip)
+	}
+	log.Printf("Node %s is listening...", n.config.Address)
+	return n.grpcServer.Serve(listener)
+}
+
+// Stop gracefully stops the gRPC server, waits for the node's background
+// goroutines to exit, releases its peer connections and closes its store.
+// With gossip and a LeaveTimeout, the node first leaves the cluster, so its
+// peers stop routing to it before it stops serving.
+func (n *Node) Stop() {
+	n.stopOnce.Do(func() {
+		if n.server.members != nil && n.config.LeaveTimeout > 0 {
+			n.leave()
+		}
+		n.grpcServer.GracefulStop()
+		n.background.Stop()
+		n.server.peers.close()
+		if n.recorder != nil {
+			if err := n.recorder.close(); err != nil {
+				log.Printf("Failed to close request recording: %v", err)
+			}
+		}
+		if err := n.server.store.Close(); err != nil {
+			log.Printf("Failed to close store: %v", err)
+		}
+	})
+}
+
+// sweepExpired reclaims keys whose TTL has elapsed and soft-deleted keys
+// whose retention window has passed, then compacts the store if configured.
+func (n *Node) sweepExpired(ctx context.Context) {
+	if removed := n.server.store.DeleteExpired(); removed > 0 {
+		log.Printf("Removed %d expired or purged keys", removed)
+	}
+	if compactor, ok := n.server.store.(store.Compactor); ok && n.config.CompactionThreshold > 0 {
+		if compactor.Compact(n.config.CompactionThreshold) {
+			log.Printf("Compacted the store")
+		}
+	}
+}
+
+// evictIdlePeers closes peer connections that have gone unused.
+func (n *Node) evictIdlePeers(ctx context.Context) {
+	if evicted := n.server.peers.evictIdle(n.config.PeerIdleTimeout); evicted > 0 {
+		log.Printf("Closed %d idle peer connections", evicted)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// deadlineInterceptor applies a default deadline to requests that arrive
+// without one. Forwarded calls reuse the request context, so every hop after
+// the first inherits whatever is left of the original budget.
+func deadlineInterceptor(timeout time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return handler(ctx, req)
+	}
+}
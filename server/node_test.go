@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	pb "distributed-kv-store/kvstore"
+	"distributed-kv-store/store"
+
+	"go.uber.org/goleak"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// startCluster starts size nodes on loopback ports, each configured by mod
+// if it is not nil, and stops them when the test ends.
+func startCluster(t testing.TB, size int, mod func(*Config)) []*Node {
+	t.Helper()
+	listeners := make([]net.Listener, size)
+	addrs := make([]string, size)
+	for i := range listeners {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listeners[i] = l
+		addrs[i] = l.Addr().String()
+	}
+	nodes := make([]*Node, size)
+	for i, addr := range addrs {
+		cfg := Config{Address: addr, Nodes: addrs, VirtualNodes: 3, RequestTimeout: 5 * time.Second}
+		if mod != nil {
+			mod(&cfg)
+		}
+		nodes[i] = NewNode(cfg)
+		served := make(chan struct{})
+		go func(n *Node, l net.Listener) {
+			defer close(served)
+			n.Serve(l)
+		}(nodes[i], listeners[i])
+		t.Cleanup(func() {
+			nodes[i].Stop()
+			<-served
+		})
+	}
+	return nodes
+}
+
+// dial returns a client of node, closed when the test ends.
+func dial(t testing.TB, node *Node) pb.KeyValueServiceClient {
+	t.Helper()
+	conn, err := grpc.NewClient(node.Address(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewKeyValueServiceClient(conn)
+}
+
+type countingSink struct{ published chan store.Change }
+
+func (s countingSink) Publish(c store.Change) error {
+	select {
+	case s.published <- c:
+	default:
+	}
+	return nil
+}
+
+func TestNodeStopLeavesNoGoroutines(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	// Run inside a subtest so every node, connection and cleanup is gone by
+	// the time goroutines are checked.
+	t.Run("cluster", func(t *testing.T) {
+		nodes := startCluster(t, 3, func(cfg *Config) {
+			cfg.ReplicationFactor = 2
+			cfg.SweepInterval = 10 * time.Millisecond
+			cfg.PeerIdleTimeout = 50 * time.Millisecond
+			cfg.SLOLatency = time.Second
+			cfg.SLOWindow = 100 * time.Millisecond
+			cfg.ChangeSink = countingSink{published: make(chan store.Change, 1)}
+			cfg.EvictionCallback = func(store.Eviction) {}
+			cfg.GossipInterval = 10 * time.Millisecond
+			cfg.ClusterSecret = "s3cret"
+			cfg.FailureTimeout = time.Second
+			cfg.LeaveTimeout = 100 * time.Millisecond
+		})
+		client := dial(t, nodes[0])
+		ctx := context.Background()
+		// Enough keys that some are forwarded to every peer.
+		for i := 0; i < 20; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			if _, err := client.Put(ctx, &pb.PutRequest{Key: key, Value: "v", TtlSeconds: 1}); err != nil {
+				t.Fatalf("Put(%s): %v", key, err)
+			}
+			if _, err := client.Get(ctx, &pb.GetRequest{Key: key}); err != nil {
+				t.Fatalf("Get(%s): %v", key, err)
+			}
+		}
+		time.Sleep(50 * time.Millisecond) // let gossip and the sweeper run
+	})
+}
+
+// TestAcknowledgedWritesSurviveRestart writes through a node with a cold tier
+// while it is being stopped, the way main stops it on SIGINT or SIGTERM, and
+// checks every write that was acknowledged is read back after a restart on
+// the same directory.
+func TestAcknowledgedWritesSurviveRestart(t *testing.T) {
+	dir := t.TempDir()
+	withCold := func(cfg *Config) {
+		cold, err := store.NewDirColdStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.ColdStore = cold
+	}
+	node := startCluster(t, 1, withCold)[0]
+	client := dial(t, node)
+	ctx := context.Background()
+
+	var (
+		mu    sync.Mutex
+		acked []string
+		wg    sync.WaitGroup
+	)
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				key := fmt.Sprintf("key-%d-%d", w, i)
+				if _, err := client.Put(ctx, &pb.PutRequest{Key: key, Value: key}); err != nil {
+					return // the node has stopped accepting requests
+				}
+				mu.Lock()
+				acked = append(acked, key)
+				mu.Unlock()
+			}
+		}(w)
+	}
+	time.Sleep(50 * time.Millisecond)
+	node.Stop()
+	wg.Wait()
+	if len(acked) == 0 {
+		t.Fatal("no writes were acknowledged before the node stopped")
+	}
+
+	restarted := dial(t, startCluster(t, 1, withCold)[0])
+	for _, key := range acked {
+		resp, err := restarted.Get(ctx, &pb.GetRequest{Key: key})
+		if err != nil || !resp.Found || resp.Value != key {
+			t.Fatalf("Get(%s) after restart = %v, %v; the acknowledged write was lost", key, resp, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	pb "distributed-kv-store/kvstore"
+)
+
+// pinSet is one immutable version of the key pins.
+type pinSet struct {
+	version uint64
+	pins    map[string]string // key -> node
+	encoded string            // canonical form, to break ties between equal versions
+}
+
+func newPinSet(version uint64, pins map[string]string) *pinSet {
+	keys := make([]string, 0, len(pins))
+	for key := range pins {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(key)
+		b.WriteByte(0)
+		b.WriteString(pins[key])
+		b.WriteByte(0)
+	}
+	return &pinSet{version: version, pins: pins, encoded: b.String()}
+}
+
+// newer reports whether p should replace current. A higher version wins;
+// two different tables with the same version are ordered by content, so
+// every node settles on the same one.
+func (p *pinSet) newer(current *pinSet) bool {
+	if p.version != current.version {
+		return p.version > current.version
+	}
+	return p.encoded > current.encoded
+}
+
+// pinTable holds the keys pinned to a designated node regardless of the
+// hash ring. With gossip the table travels with membership and every node
+// adopts the highest version it hears of; without it every node must be
+// configured with the same pins.
+type pinTable struct {
+	current atomic.Pointer[pinSet]
+}
+
+func newPinTable(version uint64, pins map[string]string) *pinTable {
+	t := &pinTable{}
+	t.current.Store(newPinSet(version, pins))
+	return t
+}
+
+// lookup returns the node key is pinned to, if any.
+func (t *pinTable) lookup(key string) (string, bool) {
+	pins := t.current.Load().pins
+	if len(pins) == 0 {
+		return "", false
+	}
+	node, ok := pins[key]
+	return node, ok
+}
+
+// proto returns the table in its wire form.
+func (t *pinTable) proto() *pb.PinTable {
+	set := t.current.Load()
+	return &pb.PinTable{Version: set.version, Pins: set.pins}
+}
+
+// merge adopts a peer's table if it is newer than ours.
+func (t *pinTable) merge(remote *pb.PinTable) {
+	if remote == nil {
+		return
+	}
+	set := newPinSet(remote.Version, remote.Pins)
+	for {
+		current := t.current.Load()
+		if !set.newer(current) {
+			return
+		}
+		if t.current.CompareAndSwap(current, set) {
+			log.Printf("Adopted key pins version %d (%d pins)", set.version, len(set.pins))
+			return
+		}
+	}
+}
+
+// pinned returns the node key is pinned to, provided that node is in the
+// ring. A key pinned to a node that has failed or left is placed by the ring
+// as usual until the node is back.
+func (s *Server) pinned(key string) (string, bool) {
+	node, ok := s.pins.lookup(key)
+	if !ok || !s.hashRing.HasNode(node) {
+		return "", false
+	}
+	return node, true
+}
+
+// primary returns the first node responsible for key: its pinned node, or
+// otherwise the ring's choice.
+func (s *Server) primary(key string) string {
+	if node, ok := s.pinned(key); ok {
+		return node
+	}
+	return s.hashRing.GetNode(key)
+}
+
+// pinFirst puts node at the front of nodes, keeping at most n of them.
+func pinFirst(node string, nodes []string, n int) []string {
+	out := []string{node}
+	for _, other := range nodes {
+		if len(out) == n {
+			break
+		}
+		if other != node {
+			out = append(out, other)
+		}
+	}
+	return out
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "distributed-kv-store/kvstore"
+
+	"google.golang.org/grpc"
+)
+
+// connPool keeps client connections to each peer so forwarded requests do
+// not pay for a new dial every time. gRPC multiplexes calls over a single
+// connection, so one per peer is the default. Up to maxConns can be opened
+// when every connection to a peer already carries streams in-flight calls,
+// which helps when a peer's per-connection stream limit or head-of-line
+// blocking on one TCP connection becomes the bottleneck. Connections that
+// have been idle for a while can be closed with evictIdle, down to minConns
+// per peer, and are re-dialed on next use.
+type connPool struct {
+	minConns int
+	maxConns int
+	streams  int64
+	dialOpts []grpc.DialOption
+
+	mu    sync.Mutex
+	conns map[string][]*pooledConn
+}
+
+// pooledConn is a pooled connection that tracks when it was last used and
+// how many calls are running on it, so eviction never closes it under an
+// in-flight request.
+type pooledConn struct {
+	*grpc.ClientConn
+	lastUsed atomic.Int64 // unix nanoseconds
+	active   atomic.Int64
+}
+
+func newConnPool(minConns, maxConns, streams int, dialOpts ...grpc.DialOption) *connPool {
+	if maxConns < 1 {
+		maxConns = 1
+	}
+	if streams < 1 {
+		streams = 32
+	}
+	return &connPool{
+		minConns: min(minConns, maxConns),
+		maxConns: maxConns,
+		streams:  int64(streams),
+		dialOpts: append([]grpc.DialOption{grpc.WithInsecure()}, dialOpts...),
+		conns:    make(map[string][]*pooledConn),
+	}
+}
+
+// client returns a client for addr on its least busy connection, dialing a
+// new connection on first use or when all of them are busy.
+func (p *connPool) client(addr string) (pb.KeyValueServiceClient, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	conns := p.conns[addr]
+	var conn *pooledConn
+	for _, c := range conns {
+		if conn == nil || c.active.Load() < conn.active.Load() {
+			conn = c
+		}
+	}
+	if conn == nil || conn.active.Load() >= p.streams && len(conns) < p.maxConns {
+		cc, err := grpc.Dial(addr, p.dialOpts...)
+		if err != nil && conn == nil {
+			return nil, err
+		}
+		if err == nil {
+			conn = &pooledConn{ClientConn: cc}
+			p.conns[addr] = append(conns, conn)
+		}
+	}
+	// Handing the client out counts as use, so it is not evicted before the
+	// caller gets to make its call.
+	conn.lastUsed.Store(time.Now().UnixNano())
+	return pb.NewKeyValueServiceClient(conn), nil
+}
+
+// evictIdle closes and forgets connections that have had no calls running
+// for at least idle, keeping minConns per peer.
+func (p *connPool) evictIdle(idle time.Duration) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cutoff := time.Now().Add(-idle).UnixNano()
+	evicted := 0
+	for addr, conns := range p.conns {
+		kept := conns[:0]
+		for i, conn := range conns {
+			open := len(kept) + (len(conns) - i)
+			if open > p.minConns && conn.active.Load() == 0 && conn.lastUsed.Load() < cutoff {
+				conn.Close()
+				evicted++
+				continue
+			}
+			kept = append(kept, conn)
+		}
+		if len(kept) == 0 {
+			delete(p.conns, addr)
+		} else {
+			p.conns[addr] = kept
+		}
+	}
+	return evicted
+}
+
+// close closes every pooled connection.
+func (p *connPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for addr, conns := range p.conns {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		delete(p.conns, addr)
+	}
+}
+
+func (c *pooledConn) begin() {
+	c.active.Add(1)
+	c.lastUsed.Store(time.Now().UnixNano())
+}
+
+func (c *pooledConn) end() {
+	c.lastUsed.Store(time.Now().UnixNano())
+	c.active.Add(-1)
+}
+
+func (c *pooledConn) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	c.begin()
+	defer c.end()
+	return c.ClientConn.Invoke(ctx, method, args, reply, opts...)
+}
+
+func (c *pooledConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	c.begin()
+	stream, err := c.ClientConn.NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		c.end()
+		return nil, err
+	}
+	ps := &pooledStream{ClientStream: stream}
+	end := func() { ps.once.Do(c.end) }
+	// A stream ends when a receive fails (including io.EOF) or its context
+	// is cancelled, whichever the caller runs into first.
+	ps.stop = context.AfterFunc(ctx, end)
+	ps.end = end
+	return ps, nil
+}
+
+// pooledStream marks its connection idle once the stream is over.
+type pooledStream struct {
+	grpc.ClientStream
+	once sync.Once
+	end  func()
+	stop func() bool
+}
+
+func (s *pooledStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.stop()
+		s.end()
+	}
+	return err
+}
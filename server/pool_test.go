@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "distributed-kv-store/kvstore"
+)
+
+// TestPoolConnectionLimit holds many concurrent calls open against one peer
+// and checks the pool spreads them over at most maxConns connections, then
+// evicts back down to minConns once they finish.
+func TestPoolConnectionLimit(t *testing.T) {
+	peer := startCluster(t, 1, nil)[0]
+	const (
+		minConns = 1
+		maxConns = 3
+		streams  = 2
+		calls    = 50
+	)
+	pool := newConnPool(minConns, maxConns, streams)
+	defer pool.close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	errs := make(chan error, calls)
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, err := pool.client(peer.Address())
+			if err != nil {
+				errs <- err
+				return
+			}
+			// An unread stream stays in flight until ctx is cancelled.
+			if _, err := client.Scan(ctx, &pb.ScanRequest{Local: true}); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	conns := pool.conns[peer.Address()]
+	if len(conns) != maxConns {
+		t.Fatalf("%d calls in flight opened %d connections, want %d", calls, len(conns), maxConns)
+	}
+	var active int64
+	for _, conn := range conns {
+		active += conn.active.Load()
+	}
+	if active != calls {
+		t.Fatalf("pool counts %d calls in flight, want %d", active, calls)
+	}
+
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		active = 0
+		for _, conn := range conns {
+			active += conn.active.Load()
+		}
+		if active == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d calls still counted in flight after their context was cancelled", active)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if evicted := pool.evictIdle(0); evicted != maxConns-minConns {
+		t.Fatalf("evictIdle closed %d connections, want %d", evicted, maxConns-minConns)
+	}
+	if got := len(pool.conns[peer.Address()]); got != minConns {
+		t.Fatalf("%d connections left after eviction, want %d", got, minConns)
+	}
+}
+
+// BenchmarkForwardedGet measures Gets of 4KB values that the receiving node
+// forwards to their owner, from 256 concurrent callers, with one pooled
+// connection to the owner and with up to four.
+func BenchmarkForwardedGet(b *testing.B) {
+	for _, maxConns := range []int{1, 4} {
+		b.Run(fmt.Sprintf("max-conns=%d", maxConns), func(b *testing.B) {
+			nodes := startCluster(b, 2, func(cfg *Config) { cfg.PeerMaxConns = maxConns })
+			owner := nodes[1]
+			value := strings.Repeat("v", 4<<10)
+			var keys []string
+			for i := 0; len(keys) < 1024; i++ {
+				key := fmt.Sprintf("key-%d", i)
+				if owner.server.primary(key) == owner.Address() {
+					owner.Store().Put(key, value)
+					keys = append(keys, key)
+				}
+			}
+			client := dial(b, nodes[0])
+			ctx := context.Background()
+			var next atomic.Int64
+
+			b.SetParallelism(max(1, 256/runtime.GOMAXPROCS(0)))
+			b.ResetTimer()
+			b.RunParallel(func(p *testing.PB) {
+				for p.Next() {
+					key := keys[next.Add(1)%int64(len(keys))]
+					if _, err := client.Get(ctx, &pb.GetRequest{Key: key}); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
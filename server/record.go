@@ -0,0 +1,228 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	pb "distributed-kv-store/kvstore"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// recordedValueLimit is how many bytes of a Put value are kept in a recording.
+const recordedValueLimit = 256
+
+// RecordedRequest is one line of a request recording.
+type RecordedRequest struct {
+	Time    time.Time       `json:"time"`
+	Method  string          `json:"method"` // RPC name, e.g. "Put"
+	Code    string          `json:"code"`   // status the node answered with
+	Request json.RawMessage `json:"request"`
+}
+
+// requestRecorder appends a sample of the data requests clients send to this
+// node to a file as JSON lines, for replaying elsewhere with Replay. Requests
+// forwarded by other nodes are not recorded, so each client request is
+// recorded at most once across the cluster. Values are truncated to
+// recordedValueLimit bytes. Recording stops once the file reaches maxBytes.
+type requestRecorder struct {
+	path     string
+	rate     float64
+	maxBytes int64
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	rand *rand.Rand
+}
+
+func newRequestRecorder(path string, rate float64, maxBytes int64) *requestRecorder {
+	if path == "" {
+		return nil
+	}
+	if rate <= 0 || rate > 1 {
+		rate = 1
+	}
+	return &requestRecorder{path: path, rate: rate, maxBytes: maxBytes, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// open opens the recording for appending.
+func (r *requestRecorder) open() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file, r.size = file, info.Size()
+	return nil
+}
+
+func (r *requestRecorder) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *requestRecorder) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	operation := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]
+	if !auditedOperations[operation] || forwardedBy(ctx) != "" {
+		return resp, err
+	}
+	if msg, ok := req.(proto.Message); ok {
+		r.record(operation, msg, err)
+	}
+	return resp, err
+}
+
+func (r *requestRecorder) record(operation string, req proto.Message, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil || r.rand.Float64() >= r.rate {
+		return
+	}
+	if r.maxBytes > 0 && r.size >= r.maxBytes {
+		return
+	}
+	req = truncateValues(req)
+	body, marshalErr := protojson.Marshal(req)
+	if marshalErr != nil {
+		return
+	}
+	line, marshalErr := json.Marshal(RecordedRequest{
+		Time:    time.Now(),
+		Method:  operation,
+		Code:    status.Code(err).String(),
+		Request: body,
+	})
+	if marshalErr != nil {
+		return
+	}
+	line = append(line, '\n')
+	n, writeErr := r.file.Write(line)
+	r.size += int64(n)
+	if writeErr != nil {
+		log.Printf("Failed to record request: %v", writeErr)
+	} else if r.maxBytes > 0 && r.size >= r.maxBytes {
+		log.Printf("Request recording %s reached %d bytes, no longer recording", r.path, r.maxBytes)
+	}
+}
+
+// truncateValues returns req with its value cut to recordedValueLimit bytes,
+// cloning it rather than modifying the request being served.
+func truncateValues(req proto.Message) proto.Message {
+	switch r := req.(type) {
+	case *pb.PutRequest:
+		if len(r.Value) > recordedValueLimit || len(r.ValueBytes) > recordedValueLimit {
+			r = proto.Clone(r).(*pb.PutRequest)
+			r.Value, r.ValueBytes = truncateValue(r.Value, r.ValueBytes)
+			return r
+		}
+	case *pb.PutContentRequest:
+		if len(r.Value) > recordedValueLimit || len(r.ValueBytes) > recordedValueLimit {
+			r = proto.Clone(r).(*pb.PutContentRequest)
+			r.Value, r.ValueBytes = truncateValue(r.Value, r.ValueBytes)
+			return r
+		}
+	}
+	return req
+}
+
+func truncateValue(value string, valueBytes []byte) (string, []byte) {
+	if len(value) > recordedValueLimit {
+		value = strings.ToValidUTF8(value[:recordedValueLimit], "")
+	}
+	if len(valueBytes) > recordedValueLimit {
+		valueBytes = valueBytes[:recordedValueLimit]
+	}
+	return value, valueBytes
+}
+
+// Replay sends every request in a recording made with Config.RecordPath to
+// client, in order and one at a time. Requests that fail are counted, not
+// fatal, since a replay is expected to reproduce errors; only a malformed
+// recording stops it. It returns how many requests were sent and how many
+// of them failed.
+func Replay(ctx context.Context, recording io.Reader, client pb.KeyValueServiceClient) (sent, failed int, err error) {
+	scanner := bufio.NewScanner(recording)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var rec RecordedRequest
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return sent, failed, fmt.Errorf("line %d: %w", sent+1, err)
+		}
+		call, err := replayCall(ctx, client, rec)
+		if err != nil {
+			return sent, failed, fmt.Errorf("line %d: %w", sent+1, err)
+		}
+		sent++
+		if call() != nil {
+			failed++
+		}
+	}
+	return sent, failed, scanner.Err()
+}
+
+// replayCall decodes a recorded request into a call that sends it to client.
+func replayCall(ctx context.Context, client pb.KeyValueServiceClient, rec RecordedRequest) (func() error, error) {
+	var req proto.Message
+	var call func() error
+	switch rec.Method {
+	case "Put":
+		r := &pb.PutRequest{}
+		req, call = r, func() error { _, err := client.Put(ctx, r); return err }
+	case "PutContent":
+		r := &pb.PutContentRequest{}
+		req, call = r, func() error { _, err := client.PutContent(ctx, r); return err }
+	case "Get":
+		r := &pb.GetRequest{}
+		req, call = r, func() error { _, err := client.Get(ctx, r); return err }
+	case "GetOrDefault":
+		r := &pb.GetOrDefaultRequest{}
+		req, call = r, func() error { _, err := client.GetOrDefault(ctx, r); return err }
+	case "Delete":
+		r := &pb.DeleteRequest{}
+		req, call = r, func() error { _, err := client.Delete(ctx, r); return err }
+	case "BatchDelete":
+		r := &pb.BatchDeleteRequest{}
+		req, call = r, func() error { _, err := client.BatchDelete(ctx, r); return err }
+	case "Touch":
+		r := &pb.TouchRequest{}
+		req, call = r, func() error { _, err := client.Touch(ctx, r); return err }
+	case "Undelete":
+		r := &pb.UndeleteRequest{}
+		req, call = r, func() error { _, err := client.Undelete(ctx, r); return err }
+	case "Rename":
+		r := &pb.RenameRequest{}
+		req, call = r, func() error { _, err := client.Rename(ctx, r); return err }
+	default:
+		return nil, fmt.Errorf("cannot replay method %q", rec.Method)
+	}
+	if err := protojson.Unmarshal(rec.Request, req); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
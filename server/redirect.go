@@ -0,0 +1,35 @@
+package server
+
+import (
+	pb "distributed-kv-store/kvstore"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// redirectErr tells the client to retry its request against node. The
+// status carries a pb.Redirect detail so clients need not parse the message.
+func redirectErr(node string) error {
+	st, err := status.New(codes.FailedPrecondition, "redirect: key is owned by "+node).
+		WithDetails(&pb.Redirect{Node: node})
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return st.Err()
+}
+
+// RedirectTarget reports the node a redirect error sent by a node in
+// redirect mode points to. Clients should retry the request there and may
+// cache the key's owner until the ring changes.
+func RedirectTarget(err error) (string, bool) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return "", false
+	}
+	for _, detail := range st.Details() {
+		if redirect, ok := detail.(*pb.Redirect); ok {
+			return redirect.Node, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"distributed-kv-store/hash"
+
+	"google.golang.org/grpc"
+)
+
+// replicationGuard watches the ring for fewer nodes than the replication
+// factor. GetNodes can only return as many replicas as there are nodes, so a
+// smaller cluster silently runs with fewer. By default the guard logs a
+// warning and the factor is effectively clamped to the node count; with
+// RequireReplicas it also rejects writes until enough nodes are back. With
+// gossip the ring holds the live members, so it is re-evaluated whenever a
+// node fails, leaves or joins.
+type replicationGuard struct {
+	ring    *hash.HashRing
+	factor  int
+	require bool
+	short   atomic.Bool // the ring has fewer nodes than factor
+}
+
+func newReplicationGuard(ring *hash.HashRing, factor int, require bool) *replicationGuard {
+	g := &replicationGuard{ring: ring, factor: factor, require: require}
+	g.check()
+	return g
+}
+
+// check compares the ring's size with the factor and logs when that flips.
+func (g *replicationGuard) check() {
+	nodes := len(g.ring.Nodes())
+	short := nodes < g.factor
+	if g.short.Swap(short) == short {
+		return
+	}
+	switch {
+	case short && g.require:
+		log.Printf("Only %d nodes for replication factor %d; rejecting writes until more join", nodes, g.factor)
+	case short:
+		log.Printf("Only %d nodes for replication factor %d; keys have at most %d replicas", nodes, g.factor, nodes)
+	default:
+		log.Printf("Cluster has %d nodes, enough for replication factor %d", nodes, g.factor)
+	}
+}
+
+// watch re-evaluates the guard every time the ring changes until ctx is done.
+func (g *replicationGuard) watch(ctx context.Context) {
+	for {
+		changed := g.ring.Changed()
+		g.check()
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		}
+	}
+}
+
+// intercept rejects write requests with ErrUnderReplicated while the ring is
+// too small and replicas are required.
+func (g *replicationGuard) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if g.require && g.short.Load() {
+		if operation := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]; writeOperations[operation] {
+			return nil, toStatus(fmt.Errorf("%w: %d nodes for replication factor %d", ErrUnderReplicated, len(g.ring.Nodes()), g.factor))
+		}
+	}
+	return handler(ctx, req)
+}
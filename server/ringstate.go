@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"distributed-kv-store/hash"
+)
+
+// ringState is the membership a node saves so that after a restart it can
+// rejoin through every node it last knew about, not just its seeds.
+type ringState struct {
+	Epoch   uint64       `json:"epoch"`
+	Members []ringMember `json:"members"`
+}
+
+type ringMember struct {
+	Address  string        `json:"address"`
+	Metadata hash.Metadata `json:"metadata,omitempty"`
+}
+
+// loadRingState reads a saved ring state. A missing file is an empty state.
+func loadRingState(path string) (ringState, error) {
+	var state ringState
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("parse ring state %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// saveRingState writes the ring's current members and epoch to path,
+// replacing the previous state atomically.
+func saveRingState(path string, ring *hash.HashRing) error {
+	state := ringState{Epoch: ring.Epoch()}
+	for _, node := range ring.Nodes() {
+		state.Members = append(state.Members, ringMember{Address: node, Metadata: ring.Metadata(node)})
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".ring-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// syncRingState rejoins through the members saved in RingStateFile, then
+// saves the ring there every time it changes. A saved member is only added
+// back once it answers a gossip exchange, so nodes that left or failed while
+// this one was down are not routed to; gossip takes over from there.
+func (n *Node) syncRingState(ctx context.Context) {
+	path := n.config.RingStateFile
+	ring := n.server.hashRing
+	members := n.server.members
+
+	state, err := loadRingState(path)
+	if err != nil {
+		log.Printf("Failed to load ring state, joining through seeds only: %v", err)
+	}
+	ring.RestoreEpoch(state.Epoch)
+	rejoined, known := 0, 0
+	for _, member := range state.Members {
+		if member.Address == n.config.Address {
+			continue
+		}
+		known++
+		members.remember(member.Address, member.Metadata)
+		exchangeCtx, cancel := context.WithTimeout(ctx, n.config.GossipInterval)
+		err := members.exchange(exchangeCtx, n.server.peers, member.Address)
+		cancel()
+		if err != nil {
+			log.Printf("Saved member %s is unreachable, not rejoining through it: %v", member.Address, err)
+			continue
+		}
+		rejoined++
+	}
+	if known > 0 {
+		log.Printf("Rejoined through %d of %d saved members", rejoined, known)
+	}
+
+	for {
+		changed := ring.Changed()
+		if err := saveRingState(path, ring); err != nil {
+			log.Printf("Failed to save ring state: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		}
+	}
+}
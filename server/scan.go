@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"distributed-kv-store/hash"
+	pb "distributed-kv-store/kvstore"
+	"distributed-kv-store/store"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Scan streams every key with the given prefix and, if a range is given,
+// whose ring position falls in it. Disjoint ranges (see hash.SplitRing) can
+// be scanned concurrently to export a node or cluster in parallel. Unless the request is local,
+// the receiving node fans out to every node in the ring and streams results
+// as they arrive instead of buffering them. Cancelling the stream stops the
+// scan on every node. Nodes still scanning when the fan-out budget runs out
+// are cut off, and the scan ends successfully with them listed in the
+// TimedOutNodesMetadataKey trailer. Value filters are applied on each node,
+// so entries they reject are never sent between nodes, and once limit
+// entries have been sent the scan stops on every node.
+func (s *Server) Scan(req *pb.ScanRequest, stream pb.KeyValueService_ScanServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	match, err := valueFilter(req)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.Local {
+		return s.scanLocal(ctx, req, match, stream.Send)
+	}
+
+	// Every node, this one included, is asked only for the keys it owns.
+	nodeReq := &pb.ScanRequest{
+		Prefix:        req.Prefix,
+		Local:         true,
+		Range:         req.Range,
+		Limit:         req.Limit,
+		ValueContains: req.ValueContains,
+		ValuePattern:  req.ValuePattern,
+		Owned:         true,
+	}
+	nodes := s.hashRing.Nodes()
+	results := make(chan *pb.KeyValue)
+	var (
+		mu       sync.Mutex
+		firstErr error
+		late     []string // nodes cut off by the fan-out budget
+		wg       sync.WaitGroup
+	)
+	nodeCtx, cancelNodes := s.fanOutContext(ctx)
+	defer cancelNodes()
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(node string) {
+			defer wg.Done()
+			err := s.scanNode(nodeCtx, node, nodeReq, match, results)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+			case timedOut(ctx, err):
+				late = append(late, node)
+			case firstErr == nil:
+				firstErr = err
+			}
+		}(node)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// gRPC streams are not safe for concurrent sends, so one loop does them all.
+	var sent uint32
+	for kv := range results {
+		if err := stream.Send(kv); err != nil {
+			return err
+		}
+		if sent++; sent == req.Limit {
+			// Stop the remaining node scans and let their goroutines finish.
+			cancelNodes()
+			for range results {
+			}
+			return nil
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	if len(late) > 0 {
+		sort.Strings(late)
+		stream.SetTrailer(metadata.Pairs(TimedOutNodesMetadataKey, strings.Join(late, ",")))
+	}
+	return nil
+}
+
+// scanNode scans one node with req, which must be local, and delivers its
+// results until ctx is done.
+func (s *Server) scanNode(ctx context.Context, node string, req *pb.ScanRequest, match func(string) bool, results chan<- *pb.KeyValue) error {
+	send := func(kv *pb.KeyValue) error {
+		select {
+		case results <- kv:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if node == s.currentNode {
+		return s.scanLocal(ctx, req, match, send)
+	}
+
+	client, err := s.peer(node)
+	if err != nil {
+		return toStatus(err)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, CallerMetadataKey, callerIdentity(ctx))
+	peerStream, err := client.Scan(ctx, req)
+	if err != nil {
+		return toStatus(s.forwardErr(node, err))
+	}
+	for {
+		kv, err := peerStream.Recv()
+		if err == io.EOF {
+			s.recordHealth(node, nil)
+			return nil
+		}
+		if err != nil {
+			return toStatus(s.forwardErr(node, err))
+		}
+		if err := send(kv); err != nil {
+			return err
+		}
+	}
+}
+
+// scanLocal scans this node's store for the keys req selects whose values
+// match, stopping early if ctx is done, send fails or req.Limit entries have
+// been sent. With req.Owned, keys this node holds but is not the primary for
+// are skipped.
+func (s *Server) scanLocal(ctx context.Context, req *pb.ScanRequest, match func(string) bool, send func(*pb.KeyValue) error) error {
+	var keyRange *hash.Range
+	if req.Range != nil {
+		keyRange = &hash.Range{Start: req.Range.Start, End: req.Range.End}
+	}
+	var (
+		sendErr error
+		sent    uint32
+	)
+	s.store.Scan(req.Prefix, func(e store.Entry) bool {
+		if ctx.Err() != nil {
+			sendErr = ctx.Err()
+			return false
+		}
+		if keyRange != nil && !keyRange.Contains(s.hashRing.Position(e.Key)) {
+			return true
+		}
+		if req.Owned && s.primary(e.Key) != s.currentNode {
+			return true
+		}
+		if match != nil && !match(e.Value) {
+			return true
+		}
+		if sendErr = send(toKeyValue(e)); sendErr != nil {
+			return false
+		}
+		sent++
+		return sent != req.Limit
+	})
+	return sendErr
+}
+
+// valueFilter returns the value filter a scan request asks for, or nil if it
+// sets none.
+func valueFilter(req *pb.ScanRequest) (func(string) bool, error) {
+	if req.ValueContains == "" && req.ValuePattern == "" {
+		return nil, nil
+	}
+	var pattern *regexp.Regexp
+	if req.ValuePattern != "" {
+		var err error
+		if pattern, err = regexp.Compile(req.ValuePattern); err != nil {
+			return nil, fmt.Errorf("invalid value_pattern: %w", err)
+		}
+	}
+	return func(value string) bool {
+		if !strings.Contains(value, req.ValueContains) {
+			return false
+		}
+		return pattern == nil || pattern.MatchString(value)
+	}, nil
+}
+
+// toKeyValue converts a store entry to its wire form.
+func toKeyValue(e store.Entry) *pb.KeyValue {
+	value, valueBytes := wireValue(e.Value, false)
+	return &pb.KeyValue{
+		Key:             e.Key,
+		Value:           value,
+		ValueBytes:      valueBytes,
+		Version:         e.Version,
+		ExpiresAtUnixMs: unixMilli(e.ExpiresAt),
+		SlidingTtlMs:    e.SlidingTTL.Milliseconds(),
+		ModifiedUnixMs:  unixMilli(e.Modified),
+		ContentType:     e.ContentType,
+	}
+}
+
+// unixMilli converts t for the wire, where 0 stands for the zero time.
+func unixMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
@@ -0,0 +1,511 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"distributed-kv-store/hash"
+	pb "distributed-kv-store/kvstore"
+	"distributed-kv-store/store"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Server implements the KeyValueService and includes the hash ring.
+type Server struct {
+	pb.UnimplementedKeyValueServiceServer
+	store       store.StorageEngine
+	hashRing    *hash.HashRing
+	currentNode string
+	nodes       []string
+	peers       *connPool
+	members     *membership // nil unless gossip is enabled
+	pins        *pinTable   // keys routed to a designated node instead of by the ring
+	indexed     bool        // the store maintains a secondary index
+	redirect    bool        // tell clients where to go instead of forwarding
+
+	// fanOutTimeout bounds operations that call every node; see fanOutContext.
+	fanOutTimeout time.Duration
+
+	// replicationFactor is the number of nodes responsible for each key,
+	// placed by replicaStrategy.
+	replicationFactor int
+	replicaStrategy   hash.ReplicaStrategy
+
+	// unhealthy holds peers whose last forwarded call failed to reach them.
+	healthMu  sync.RWMutex
+	unhealthy map[string]bool
+	breakers  *breakerSet
+
+	ops        *opCounters
+	cache      *responseCache // nil unless Get responses for remote keys are cached
+	slo        *sloTracker    // nil unless a latency SLO is configured
+	adminToken string         // empty disables admin RPCs
+	dumps      *dumpGate
+
+	clusterSecret string // sent and required on calls between nodes; empty trusts member hosts
+}
+
+// recordHealth updates the health of a peer from the outcome of a forwarded call.
+func (s *Server) recordHealth(node string, err error) {
+	down := false
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		down = true
+	}
+
+	s.breakers.record(node, down)
+
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	if down {
+		s.unhealthy[node] = true
+	} else {
+		delete(s.unhealthy, node)
+	}
+}
+
+// forwardErr records a failed forwarded call and says which node it failed
+// on. A peer that could not be reached is reported as ErrNodeUnavailable,
+// naming the peer, so clients do not take it for this node being down, and
+// running out of time keeps DeadlineExceeded but names the peer too. Any
+// other error is the peer's own answer: its code and details are kept and
+// its message is prefixed with the node, so an error that crossed several
+// hops names each of them.
+func (s *Server) forwardErr(node string, err error) error {
+	s.recordHealth(node, err)
+	st, ok := status.FromError(err)
+	if !ok {
+		return fmt.Errorf("forwarding to %s: %w", node, err)
+	}
+	if st.Code() == codes.Unavailable {
+		return fmt.Errorf("%w: forwarding to %s failed: %s", ErrNodeUnavailable, node, st.Message())
+	}
+	if st.Code() == codes.DeadlineExceeded {
+		return status.Errorf(codes.DeadlineExceeded, "forwarding to %s timed out: %s", node, st.Message())
+	}
+	p := st.Proto()
+	p.Message = fmt.Sprintf("node %s replied: %s", node, p.Message)
+	return status.FromProto(p).Err()
+}
+
+// isHealthy reports whether a node is believed reachable. Nodes we have not
+// talked to yet are assumed healthy.
+func (s *Server) isHealthy(node string) bool {
+	s.healthMu.RLock()
+	defer s.healthMu.RUnlock()
+	return !s.unhealthy[node]
+}
+
+// peer returns a client for a node from the connection pool. It fails fast
+// with ErrNodeUnavailable while the node's circuit breaker is open.
+func (s *Server) peer(node string) (pb.KeyValueServiceClient, error) {
+	if !s.breakers.allow(node) {
+		return nil, fmt.Errorf("%w: %s: circuit breaker open", ErrNodeUnavailable, node)
+	}
+	client, err := s.peers.client(node)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrNodeUnavailable, node, err)
+	}
+	return client, nil
+}
+
+// replicas returns the ordered replica set for a key, led by its pinned node
+// if it has one.
+func (s *Server) replicas(key string) []string {
+	replicas := s.replicaStrategy.Replicas(s.hashRing, key, s.replicationFactor)
+	if node, ok := s.pinned(key); ok {
+		return pinFirst(node, replicas, s.replicationFactor)
+	}
+	return replicas
+}
+
+// owner returns the node responsible for a key: its pinned node, or
+// otherwise the ring's primary. Requests never fall back to another replica
+// while the primary is down, because writes are not copied to replicas: a
+// write sent elsewhere would be lost once the primary recovers, and a read
+// would report the key missing. A primary whose circuit breaker is open
+// fails fast with ErrNodeUnavailable instead.
+func (s *Server) owner(key string) (string, error) {
+	node := s.primary(key)
+	if node == "" {
+		return "", hash.ErrRingEmpty
+	}
+	return node, nil
+}
+
+// ForwardedMetadataKey is set by a node forwarding a request to the key's
+// owner, so the owner does not forward it again.
+const ForwardedMetadataKey = "x-forwarded-by"
+
+// route returns the node that should serve a request for key. A request
+// that was already forwarded by another node is never forwarded again: it is
+// served here if this node is the key's primary, and otherwise rejected with
+// ErrWrongNode rather than answered with whatever this node happens to hold.
+// Other replicas are not enough, since writes are only stored on the primary
+// and a write served elsewhere would never be read back.
+func (s *Server) route(ctx context.Context, key string) (string, error) {
+	if forwardedBy(ctx) == "" {
+		return s.owner(key)
+	}
+	if s.primary(key) != s.currentNode {
+		return "", fmt.Errorf("%w: %s does not own key %q", ErrWrongNode, s.currentNode, key)
+	}
+	return s.currentNode, nil
+}
+
+// forwardedBy returns the node that forwarded the incoming request, or "" if
+// it came straight from a client.
+func forwardedBy(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if nodes := md.Get(ForwardedMetadataKey); len(nodes) > 0 {
+			return nodes[0]
+		}
+	}
+	return ""
+}
+
+// keyedResponse is implemented by responses that echo their request key.
+type keyedResponse interface {
+	GetKey() string
+}
+
+// forward sends a request for key to another node and records the outcome
+// against its health. The caller's identity is passed along so the owner
+// sees the original client rather than this node, along with the request's
+// priority. A response that answers
+// for a different key is rejected instead of being returned to the client.
+// In redirect mode nothing is sent and the client is told to go to node.
+func forward[Resp keyedResponse](ctx context.Context, s *Server, node, key string, call func(context.Context, pb.KeyValueServiceClient) (Resp, error)) (Resp, error) {
+	var zero Resp
+	if s.redirect {
+		return zero, redirectErr(node)
+	}
+	client, err := s.peer(node)
+	if err != nil {
+		return zero, toStatus(err)
+	}
+	resp, err := call(s.forwardContext(ctx), client)
+	if err != nil {
+		return zero, toStatus(s.forwardErr(node, err))
+	}
+	s.recordHealth(node, nil)
+	if resp.GetKey() != key {
+		return zero, status.Errorf(codes.Internal, "node %s answered for key %q instead of %q", node, resp.GetKey(), key)
+	}
+	return resp, nil
+}
+
+// forwardContext marks an outgoing call as forwarded by this node on behalf
+// of the original caller, at the caller's priority.
+func (s *Server) forwardContext(ctx context.Context) context.Context {
+	ctx = metadata.AppendToOutgoingContext(ctx,
+		CallerMetadataKey, callerIdentity(ctx),
+		ForwardedMetadataKey, s.currentNode)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if p := md.Get(PriorityMetadataKey); len(p) > 0 {
+			ctx = metadata.AppendToOutgoingContext(ctx, PriorityMetadataKey, p[0])
+		}
+	}
+	return ctx
+}
+
+// Put inserts or updates a key-value pair.
+func (s *Server) Put(ctx context.Context, req *pb.PutRequest) (*pb.PutResponse, error) {
+	defer s.cache.invalidate(req.Key)
+
+	if req.TtlSeconds != 0 && req.ExpiresAtUnixMs != 0 {
+		return nil, status.Error(codes.InvalidArgument, "set ttl_seconds or expires_at_unix_ms, not both")
+	}
+
+	// Determine the responsible node for the key.
+	targetNode, err := s.route(ctx, req.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if targetNode != s.currentNode {
+		// Forward the request to the responsible node via gRPC.
+		return forward(ctx, s, targetNode, req.Key, func(ctx context.Context, client pb.KeyValueServiceClient) (*pb.PutResponse, error) {
+			return client.Put(ctx, req)
+		})
+	}
+
+	// Handle the request locally.
+	value := req.Value
+	if len(req.ValueBytes) > 0 {
+		value = string(req.ValueBytes)
+	}
+	opts := store.PutOptions{
+		TTL:             time.Duration(req.TtlSeconds) * time.Second,
+		SlidingTTL:      req.SlidingTtl,
+		ExpectedVersion: req.ExpectedVersion,
+		ContentType:     req.ContentType,
+	}
+	if req.ExpiresAtUnixMs != 0 {
+		opts.ExpireAt = time.UnixMilli(req.ExpiresAtUnixMs)
+	}
+	version, err := s.store.PutWithOptions(req.Key, value, opts)
+	var conflict *store.VersionConflictError
+	if errors.As(err, &conflict) {
+		return &pb.PutResponse{Success: false, Version: conflict.Current, Node: s.currentNode, Key: req.Key}, nil
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.PutResponse{
+		Success:  true,
+		Version:  version,
+		Node:     s.currentNode,
+		Replicas: s.replicas(req.Key),
+		Key:      req.Key,
+	}, nil
+}
+
+// PutContent stores a value under its content hash (see store.ContentKey) on
+// the node that owns that key. Identical content always maps to the same key,
+// and putting it again leaves the stored copy and its version unchanged.
+func (s *Server) PutContent(ctx context.Context, req *pb.PutContentRequest) (*pb.PutContentResponse, error) {
+	value := req.Value
+	if len(req.ValueBytes) > 0 {
+		value = string(req.ValueBytes)
+	}
+	key := store.ContentKey(value)
+
+	// Determine the responsible node for the key.
+	targetNode, err := s.route(ctx, key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if targetNode != s.currentNode {
+		// Forward the request to the responsible node via gRPC.
+		return forward(ctx, s, targetNode, key, func(ctx context.Context, client pb.KeyValueServiceClient) (*pb.PutContentResponse, error) {
+			return client.PutContent(ctx, req)
+		})
+	}
+
+	// Handle the request locally.
+	var absent uint64
+	version, err := s.store.PutWithOptions(key, value, store.PutOptions{ExpectedVersion: &absent})
+	var conflict *store.VersionConflictError
+	if errors.As(err, &conflict) {
+		version, err = conflict.Current, nil
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.PutContentResponse{Key: key, Version: version, Node: s.currentNode}, nil
+}
+
+// Get retrieves a value by key.
+func (s *Server) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
+	if req.LocalOnly {
+		return s.getLocal(req), nil
+	}
+
+	// Determine the responsible node for the key.
+	targetNode, err := s.route(ctx, req.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if targetNode != s.currentNode {
+		// Forward the request to the responsible node via gRPC.
+		if resp, ok := s.cache.get(req); ok {
+			return resp, nil
+		}
+		resp, err := forward(ctx, s, targetNode, req.Key, func(ctx context.Context, client pb.KeyValueServiceClient) (*pb.GetResponse, error) {
+			return client.Get(ctx, req)
+		})
+		if err == nil {
+			s.cache.put(req, resp)
+		}
+		return resp, err
+	}
+
+	// Handle the request locally.
+	return s.getLocal(req), nil
+}
+
+// getLocal reads a key from this node's own store.
+func (s *Server) getLocal(req *pb.GetRequest) *pb.GetResponse {
+	e, found := s.store.GetWithMetadata(req.Key)
+	resp := &pb.GetResponse{
+		Found:          found,
+		Version:        e.Version,
+		Key:            req.Key,
+		ModifiedUnixMs: unixMilli(e.Modified),
+		ContentType:    e.ContentType,
+	}
+	resp.Value, resp.ValueBytes = wireValue(e.Value, req.AsBytes)
+	if !found {
+		resp.RecentlyDeleted = s.store.RecentlyDeleted(req.Key)
+	}
+	return resp
+}
+
+// GetOrDefault retrieves a value by key, returning the supplied default if the
+// key is absent. Unlike GetOrSet nothing is stored.
+func (s *Server) GetOrDefault(ctx context.Context, req *pb.GetOrDefaultRequest) (*pb.GetResponse, error) {
+	// Determine the responsible node for the key.
+	targetNode, err := s.route(ctx, req.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if targetNode != s.currentNode {
+		// Forward the request to the responsible node via gRPC.
+		return forward(ctx, s, targetNode, req.Key, func(ctx context.Context, client pb.KeyValueServiceClient) (*pb.GetResponse, error) {
+			return client.GetOrDefault(ctx, req)
+		})
+	}
+
+	// Handle the request locally.
+	e, found := s.store.GetWithMetadata(req.Key)
+	if !found {
+		e.Value = req.DefaultValue
+	}
+	resp := &pb.GetResponse{
+		Found:          found,
+		Version:        e.Version,
+		Key:            req.Key,
+		ModifiedUnixMs: unixMilli(e.Modified),
+		ContentType:    e.ContentType,
+	}
+	resp.Value, resp.ValueBytes = wireValue(e.Value, false)
+	if !found {
+		resp.RecentlyDeleted = s.store.RecentlyDeleted(req.Key)
+	}
+	return resp, nil
+}
+
+// wireValue splits a stored value between a response's string and bytes
+// fields. Protobuf strings must be valid UTF-8, so anything else always goes
+// out as bytes.
+func wireValue(value string, asBytes bool) (string, []byte) {
+	if asBytes || !utf8.ValidString(value) {
+		return "", []byte(value)
+	}
+	return value, nil
+}
+
+// Delete removes a key-value pair.
+func (s *Server) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	defer s.cache.invalidate(req.Key)
+
+	// Determine the responsible node for the key.
+	targetNode, err := s.route(ctx, req.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if targetNode != s.currentNode {
+		// Forward the request to the responsible node via gRPC.
+		return forward(ctx, s, targetNode, req.Key, func(ctx context.Context, client pb.KeyValueServiceClient) (*pb.DeleteResponse, error) {
+			return client.Delete(ctx, req)
+		})
+	}
+
+	// Handle the request locally. Deleting a missing key is not an error.
+	if err := s.store.Delete(req.Key); err != nil && !errors.Is(err, store.ErrKeyNotFound) {
+		return nil, toStatus(err)
+	}
+	return &pb.DeleteResponse{Success: true, Key: req.Key}, nil
+}
+
+// Undelete restores a soft-deleted key within its retention window.
+func (s *Server) Undelete(ctx context.Context, req *pb.UndeleteRequest) (*pb.UndeleteResponse, error) {
+	defer s.cache.invalidate(req.Key)
+
+	// Determine the responsible node for the key.
+	targetNode, err := s.route(ctx, req.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if targetNode != s.currentNode {
+		// Forward the request to the responsible node via gRPC.
+		return forward(ctx, s, targetNode, req.Key, func(ctx context.Context, client pb.KeyValueServiceClient) (*pb.UndeleteResponse, error) {
+			return client.Undelete(ctx, req)
+		})
+	}
+
+	// Handle the request locally.
+	version, err := s.store.Undelete(req.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.UndeleteResponse{Version: version, Key: req.Key}, nil
+}
+
+// Touch resets a key's TTL without reading or rewriting its value.
+func (s *Server) Touch(ctx context.Context, req *pb.TouchRequest) (*pb.TouchResponse, error) {
+	defer s.cache.invalidate(req.Key)
+
+	// Determine the responsible node for the key.
+	targetNode, err := s.route(ctx, req.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if targetNode != s.currentNode {
+		// Forward the request to the responsible node via gRPC.
+		return forward(ctx, s, targetNode, req.Key, func(ctx context.Context, client pb.KeyValueServiceClient) (*pb.TouchResponse, error) {
+			return client.Touch(ctx, req)
+		})
+	}
+
+	// Handle the request locally.
+	touched := s.store.Touch(req.Key, time.Duration(req.TtlSeconds)*time.Second)
+	return &pb.TouchResponse{Touched: touched, Key: req.Key}, nil
+}
+
+// Rename moves a value to a new key on the node that owns both keys. Keys
+// owned by different nodes are rejected with ErrCrossNodeRename, naming
+// both owners, rather than moved non-atomically; callers that accept a
+// window where both or neither key exists can copy and delete themselves.
+func (s *Server) Rename(ctx context.Context, req *pb.RenameRequest) (*pb.RenameResponse, error) {
+	defer s.cache.invalidate(req.Key, req.NewKey)
+
+	if from, to := s.primary(req.Key), s.primary(req.NewKey); from != to {
+		return nil, toStatus(fmt.Errorf("%w: %q is on %s and %q on %s; Get and Put the value, then Delete the old key, to move it non-atomically",
+			ErrCrossNodeRename, req.Key, from, req.NewKey, to))
+	}
+	// Determine the responsible node for the keys.
+	targetNode, err := s.route(ctx, req.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if targetNode != s.currentNode {
+		// Forward the request to the responsible node via gRPC.
+		return forward(ctx, s, targetNode, req.Key, func(ctx context.Context, client pb.KeyValueServiceClient) (*pb.RenameResponse, error) {
+			return client.Rename(ctx, req)
+		})
+	}
+
+	// Handle the request locally.
+	renamed := s.store.Rename(req.Key, req.NewKey)
+	return &pb.RenameResponse{Renamed: renamed, Key: req.Key}, nil
+}
+
+// Locate reports the nodes responsible for a key and whether each is believed healthy.
+func (s *Server) Locate(ctx context.Context, req *pb.LocateRequest) (*pb.LocateResponse, error) {
+	resp := &pb.LocateResponse{ReplicationFactor: int32(s.replicationFactor)}
+	for _, node := range s.replicas(req.Key) {
+		resp.Replicas = append(resp.Replicas, &pb.ReplicaLocation{
+			Node:    node,
+			Healthy: node == s.currentNode || s.isHealthy(node),
+		})
+	}
+	return resp, nil
+}
+
+// RingInfo exports a snapshot of this node's view of the hash ring as JSON.
+func (s *Server) RingInfo(ctx context.Context, req *pb.RingInfoRequest) (*pb.RingInfoResponse, error) {
+	layout, err := json.Marshal(s.hashRing.Layout())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.RingInfoResponse{LayoutJson: string(layout)}, nil
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	pb "distributed-kv-store/kvstore"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// wireBytes returns the value a response carries, in whichever field it is.
+func wireBytes(value string, valueBytes []byte) []byte {
+	if len(valueBytes) > 0 {
+		return valueBytes
+	}
+	return []byte(value)
+}
+
+func TestBytesRoundTrip(t *testing.T) {
+	nodes := startCluster(t, 3, nil)
+	client := dial(t, nodes[0])
+	ctx := context.Background()
+
+	values := map[string][]byte{
+		"utf-8":     []byte("héllo"),
+		"non-utf-8": {0xff, 0xfe, 0x00, 0x80, 'a'},
+		"nul bytes": {0, 0, 0},
+	}
+	// Several keys per value so that some are forwarded to other nodes.
+	for name, value := range values {
+		for i := 0; i < 5; i++ {
+			key := fmt.Sprintf("%s/%d", name, i)
+			if _, err := client.Put(ctx, &pb.PutRequest{Key: key, ValueBytes: value}); err != nil {
+				t.Fatalf("Put(%s): %v", key, err)
+			}
+			for _, asBytes := range []bool{false, true} {
+				resp, err := client.Get(ctx, &pb.GetRequest{Key: key, AsBytes: asBytes})
+				if err != nil {
+					t.Fatalf("Get(%s, as_bytes=%v): %v", key, asBytes, err)
+				}
+				if got := wireBytes(resp.Value, resp.ValueBytes); !resp.Found || !bytes.Equal(got, value) {
+					t.Fatalf("Get(%s, as_bytes=%v) = %q, %v; want %q", key, asBytes, got, resp.Found, value)
+				}
+				if asBytes && len(resp.ValueBytes) == 0 {
+					t.Fatalf("Get(%s, as_bytes=true) returned the value as a string", key)
+				}
+			}
+		}
+	}
+
+	stream, err := client.Scan(ctx, &pb.ScanRequest{Prefix: "non-utf-8/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanned := 0
+	for {
+		kv, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Scan: %v", err)
+		}
+		if got := wireBytes(kv.Value, kv.ValueBytes); !bytes.Equal(got, values["non-utf-8"]) {
+			t.Fatalf("Scan returned %q for %s", got, kv.Key)
+		}
+		scanned++
+	}
+	if scanned != 5 {
+		t.Fatalf("Scan returned %d keys, want 5", scanned)
+	}
+}
+
+func TestMixedStringAndBytesWritesOverGRPC(t *testing.T) {
+	nodes := startCluster(t, 1, nil)
+	client := dial(t, nodes[0])
+	ctx := context.Background()
+
+	steps := []struct {
+		req  *pb.PutRequest
+		want []byte
+	}{
+		{&pb.PutRequest{Key: "k", Value: "text"}, []byte("text")},
+		{&pb.PutRequest{Key: "k", ValueBytes: []byte{0xc3, 0x28}}, []byte{0xc3, 0x28}},
+		{&pb.PutRequest{Key: "k", Value: "text again"}, []byte("text again")},
+		// value_bytes takes precedence when both are set.
+		{&pb.PutRequest{Key: "k", Value: "ignored", ValueBytes: []byte("bytes")}, []byte("bytes")},
+	}
+	for _, step := range steps {
+		if _, err := client.Put(ctx, step.req); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Get(ctx, &pb.GetRequest{Key: "k"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := wireBytes(resp.Value, resp.ValueBytes); !bytes.Equal(got, step.want) {
+			t.Fatalf("after Put(%v) Get = %q, want %q", step.req, got, step.want)
+		}
+	}
+}
+
+func TestEmptyValueRoundTrip(t *testing.T) {
+	nodes := startCluster(t, 1, nil)
+	client := dial(t, nodes[0])
+	ctx := context.Background()
+
+	for _, asBytes := range []bool{false, true} {
+		key := fmt.Sprintf("empty-%v", asBytes)
+		if _, err := client.Put(ctx, &pb.PutRequest{Key: key}); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Get(ctx, &pb.GetRequest{Key: key, AsBytes: asBytes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !resp.Found || resp.Value != "" || len(resp.ValueBytes) != 0 {
+			t.Fatalf("Get(%s) = %+v, want an empty value that is found", key, resp)
+		}
+	}
+	if resp, _ := client.Get(ctx, &pb.GetRequest{Key: "missing"}); resp.Found {
+		t.Fatal("Get(missing) found a key that was never written")
+	}
+}
+
+// TestClusterStatsNamespaceLimit checks that the cluster-wide namespace usage
+// sums keys over nodes but reports the per-node quota as the limit.
+func TestClusterStatsNamespaceLimit(t *testing.T) {
+	nodes := startCluster(t, 3, func(cfg *Config) {
+		cfg.NamespaceQuotas = map[string]int{"tenant:": 100}
+	})
+	client := dial(t, nodes[0])
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		if _, err := client.Put(ctx, &pb.PutRequest{Key: fmt.Sprintf("tenant:%d", i), Value: "v"}); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	resp, err := client.ClusterStats(ctx, &pb.ClusterStatsRequest{})
+	if err != nil {
+		t.Fatalf("ClusterStats: %v", err)
+	}
+	usage := resp.Total.Namespaces["tenant:"]
+	if usage == nil || usage.Keys != 10 || usage.Limit != 100 {
+		t.Fatalf("tenant: usage = %v, want 10 keys and limit 100", usage)
+	}
+}
+
+// TestRenameAcrossNodes checks that a rename between keys on different nodes
+// is refused with an error naming both owners, and leaves the key in place.
+func TestRenameAcrossNodes(t *testing.T) {
+	nodes := startCluster(t, 3, nil)
+	client := dial(t, nodes[0])
+	ctx := context.Background()
+	from := foreignKey(t, nodes[0])
+	var to string
+	for i := 0; to == ""; i++ {
+		if key := fmt.Sprintf("new-%d", i); nodes[0].server.primary(key) != nodes[0].server.primary(from) {
+			to = key
+		}
+	}
+	if _, err := client.Put(ctx, &pb.PutRequest{Key: from, Value: "v"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	_, err := client.Rename(ctx, &pb.RenameRequest{Key: from, NewKey: to})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("Rename error = %v, want FailedPrecondition", err)
+	}
+	msg := status.Convert(err).Message()
+	for _, owner := range []string{nodes[0].server.primary(from), nodes[0].server.primary(to)} {
+		if !strings.Contains(msg, owner) {
+			t.Errorf("Rename error %q does not name owner %s", msg, owner)
+		}
+	}
+	if resp, err := client.Get(ctx, &pb.GetRequest{Key: from}); err != nil || resp.Value != "v" {
+		t.Fatalf("Get(%s) after refused Rename = %v, %v", from, resp, err)
+	}
+}
+
+// TestForwardedToReplicaRejected checks that a request forwarded to a replica
+// other than the key's primary is refused, since writes are only stored on
+// the primary and one served elsewhere would never be read back.
+func TestForwardedToReplicaRejected(t *testing.T) {
+	nodes := startCluster(t, 3, func(cfg *Config) { cfg.ReplicationFactor = 2 })
+	byAddr := make(map[string]*Node)
+	for _, n := range nodes {
+		byAddr[n.Address()] = n
+	}
+	key := "key"
+	replicas := nodes[0].server.replicas(key)
+	client := dial(t, byAddr[replicas[1]])
+	ctx := metadata.AppendToOutgoingContext(context.Background(), ForwardedMetadataKey, replicas[0])
+
+	if _, err := client.Put(ctx, &pb.PutRequest{Key: key, Value: "v"}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("forwarded Put on replica %s: error = %v, want FailedPrecondition", replicas[1], err)
+	}
+	if _, err := client.Get(ctx, &pb.GetRequest{Key: key}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("forwarded Get on replica %s: error = %v, want FailedPrecondition", replicas[1], err)
+	}
+	if _, found := byAddr[replicas[1]].Store().Get(key); found {
+		t.Fatalf("replica %s stored a forwarded write", replicas[1])
+	}
+}
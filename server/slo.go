@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// sloSamples caps how many request latencies are kept for the rolling p99.
+const sloSamples = 10000
+
+// SLOStatus is the outcome of one latency SLO evaluation.
+type SLOStatus struct {
+	P99     time.Duration // p99 latency over the window
+	Target  time.Duration // the configured p99 threshold
+	Samples int           // requests in the window
+	Healthy bool          // P99 is within Target (true when there were no requests)
+}
+
+// sloTracker records request latencies and periodically compares their
+// rolling p99 against a target, calling hook whenever the SLO goes from
+// healthy to breached or back.
+type sloTracker struct {
+	target time.Duration
+	window time.Duration
+	hook   func(SLOStatus)
+
+	mu      sync.Mutex
+	samples [sloSamples]latencySample
+	next    int // index the next sample is written to
+	count   int
+
+	status atomic.Pointer[SLOStatus]
+}
+
+type latencySample struct {
+	at      time.Time
+	latency time.Duration
+}
+
+func newSLOTracker(target, window time.Duration, hook func(SLOStatus)) *sloTracker {
+	t := &sloTracker{target: target, window: window, hook: hook}
+	t.status.Store(&SLOStatus{Target: target, Healthy: true})
+	return t
+}
+
+func (t *sloTracker) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	t.record(start, time.Since(start))
+	return resp, err
+}
+
+func (t *sloTracker) record(at time.Time, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.samples[t.next] = latencySample{at: at, latency: latency}
+	t.next = (t.next + 1) % sloSamples
+	if t.count < sloSamples {
+		t.count++
+	}
+}
+
+// evaluate recomputes the p99 over the window and fires the hook if the SLO
+// changed state.
+func (t *sloTracker) evaluate(ctx context.Context) {
+	cutoff := time.Now().Add(-t.window)
+	t.mu.Lock()
+	latencies := make([]time.Duration, 0, t.count)
+	for i := 0; i < t.count; i++ {
+		if s := t.samples[i]; s.at.After(cutoff) {
+			latencies = append(latencies, s.latency)
+		}
+	}
+	t.mu.Unlock()
+
+	status := SLOStatus{Target: t.target, Samples: len(latencies), Healthy: true}
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		status.P99 = latencies[(len(latencies)*99)/100]
+		status.Healthy = status.P99 <= t.target
+	}
+	prev := t.status.Swap(&status)
+	if prev.Healthy != status.Healthy && t.hook != nil {
+		t.hook(status)
+	}
+}
+
+// current returns the result of the last evaluation.
+func (t *sloTracker) current() SLOStatus {
+	return *t.status.Load()
+}
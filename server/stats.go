@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "distributed-kv-store/kvstore"
+	"distributed-kv-store/store"
+
+	"google.golang.org/grpc"
+)
+
+// opCounters counts the client requests a node has served per RPC. Requests
+// forwarded by other nodes are not counted, so summing the counters of every
+// node gives the number of requests clients sent to the cluster.
+type opCounters struct {
+	mu     sync.RWMutex
+	counts map[string]*atomic.Uint64
+}
+
+func newOpCounters() *opCounters {
+	return &opCounters{counts: make(map[string]*atomic.Uint64)}
+}
+
+func (c *opCounters) intercept(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if forwardedBy(ctx) == "" {
+		c.add(info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:])
+	}
+	return handler(ctx, req)
+}
+
+func (c *opCounters) add(operation string) {
+	c.mu.RLock()
+	count, ok := c.counts[operation]
+	c.mu.RUnlock()
+	if !ok {
+		c.mu.Lock()
+		if count, ok = c.counts[operation]; !ok {
+			count = new(atomic.Uint64)
+			c.counts[operation] = count
+		}
+		c.mu.Unlock()
+	}
+	count.Add(1)
+}
+
+func (c *opCounters) snapshot() map[string]uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make(map[string]uint64, len(c.counts))
+	for operation, count := range c.counts {
+		out[operation] = count.Load()
+	}
+	return out
+}
+
+// Stats reports this node's key counts and size, its namespace quota usage,
+// the requests it has served, the state of its peer circuit breakers and its
+// latency SLO.
+func (s *Server) Stats(ctx context.Context, req *pb.StatsRequest) (*pb.StatsResponse, error) {
+	resp := &pb.StatsResponse{
+		Node:        s.currentNode,
+		Keys:        int64(s.store.Len()),
+		Breakers:    s.breakers.states(),
+		SoftDeleted: int64(s.store.SoftDeleted()),
+		Bytes:       s.store.Bytes(),
+		Operations:  s.ops.snapshot(),
+	}
+	if quotas, ok := s.store.(store.QuotaReporter); ok {
+		for prefix, usage := range quotas.NamespaceUsage() {
+			if resp.Namespaces == nil {
+				resp.Namespaces = make(map[string]*pb.NamespaceUsage)
+			}
+			resp.Namespaces[prefix] = &pb.NamespaceUsage{Keys: int64(usage.Keys), Limit: int64(usage.Limit)}
+		}
+	}
+	s.countOwned(resp)
+	if s.slo != nil {
+		status := s.slo.current()
+		resp.Slo = &pb.SLOStatus{
+			P99Ms:    float64(status.P99) / float64(time.Millisecond),
+			TargetMs: float64(status.Target) / float64(time.Millisecond),
+			Samples:  int64(status.Samples),
+			Healthy:  status.Healthy,
+		}
+	}
+	return resp, nil
+}
+
+// ClusterStats collects Stats from every node in the ring and sums them.
+// Nodes that cannot be reached, or do not answer within the fan-out budget,
+// are listed in the response instead of failing the call.
+func (s *Server) ClusterStats(ctx context.Context, req *pb.ClusterStatsRequest) (*pb.ClusterStatsResponse, error) {
+	var (
+		mu   sync.Mutex
+		resp = &pb.ClusterStatsResponse{Total: &pb.StatsTotals{
+			Operations: make(map[string]uint64),
+			Namespaces: make(map[string]*pb.NamespaceUsage),
+		}}
+		wg sync.WaitGroup
+	)
+	nodeCtx, cancel := s.fanOutContext(ctx)
+	defer cancel()
+	for _, node := range s.hashRing.Nodes() {
+		wg.Add(1)
+		go func(node string) {
+			defer wg.Done()
+			stats, err := s.nodeStats(nodeCtx, node)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				resp.Unreachable = append(resp.Unreachable, nodeError(ctx, node, err))
+				return
+			}
+			resp.Nodes = append(resp.Nodes, stats)
+			resp.Total.Keys += stats.OwnedKeys
+			resp.Total.Bytes += stats.Bytes
+			resp.Total.SoftDeleted += stats.SoftDeleted
+			for operation, count := range stats.Operations {
+				resp.Total.Operations[operation] += count
+			}
+			for prefix, usage := range stats.Namespaces {
+				total, ok := resp.Total.Namespaces[prefix]
+				if !ok {
+					total = &pb.NamespaceUsage{}
+					resp.Total.Namespaces[prefix] = total
+				}
+				total.Keys += usage.OwnedKeys
+				// Quotas apply to each node separately, so the limit is the
+				// configured one rather than a sum no node enforces.
+				total.Limit = max(total.Limit, usage.Limit)
+			}
+		}(node)
+	}
+	wg.Wait()
+	sort.Slice(resp.Nodes, func(i, j int) bool { return resp.Nodes[i].Node < resp.Nodes[j].Node })
+	sort.Slice(resp.Unreachable, func(i, j int) bool { return resp.Unreachable[i].Node < resp.Unreachable[j].Node })
+	return resp, nil
+}
+
+// countOwned fills in how many of the keys this node holds, in total and per
+// namespace, it is the primary for. The rest are copies left behind when a
+// joining node bootstrapped them; they are no longer routed to, so they are
+// left out of cluster totals to count each key once.
+func (s *Server) countOwned(resp *pb.StatsResponse) {
+	s.store.Scan("", func(e store.Entry) bool {
+		if s.primary(e.Key) != s.currentNode {
+			return true
+		}
+		resp.OwnedKeys++
+		for prefix, usage := range resp.Namespaces {
+			if strings.HasPrefix(e.Key, prefix) {
+				usage.OwnedKeys++
+			}
+		}
+		return true
+	})
+}
+
+// nodeStats returns the Stats of one node.
+func (s *Server) nodeStats(ctx context.Context, node string) (*pb.StatsResponse, error) {
+	if node == s.currentNode {
+		return s.Stats(ctx, &pb.StatsRequest{})
+	}
+	client, err := s.peer(node)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := client.Stats(s.forwardContext(ctx), &pb.StatsRequest{})
+	if err != nil {
+		return nil, s.forwardErr(node, err)
+	}
+	s.recordHealth(node, nil)
+	return stats, nil
+}
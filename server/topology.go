@@ -0,0 +1,27 @@
+package server
+
+import (
+	pb "distributed-kv-store/kvstore"
+)
+
+// WatchTopology streams this node's view of the ring: the current topology
+// straight away, then a new event every time nodes are added or removed.
+// Clients caching routes should drop their cache on each event. A client
+// that reconnects gets the current topology first, so nothing it missed
+// while disconnected matters. Changes that happen in quick succession may
+// be coalesced into one event.
+func (s *Server) WatchTopology(req *pb.WatchTopologyRequest, stream pb.KeyValueService_WatchTopologyServer) error {
+	ctx := stream.Context()
+	for {
+		changed := s.hashRing.Changed()
+		event := &pb.TopologyEvent{Epoch: s.hashRing.Epoch(), Nodes: s.hashRing.Nodes()}
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
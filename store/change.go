@@ -0,0 +1,66 @@
+package store
+
+import "time"
+
+// ChangeOp is the kind of write a Change records.
+type ChangeOp string
+
+const (
+	ChangePut    ChangeOp = "put"
+	ChangeDelete ChangeOp = "delete"
+)
+
+// Change is one committed write: a key being set, or a live key being
+// deleted. Keys that expire are not reported; consumers can apply ExpiresAt
+// themselves.
+type Change struct {
+	Op          ChangeOp
+	Key         string
+	Value       string    // empty for deletes
+	Version     uint64    // the new version for puts, the deleted version for deletes
+	ExpiresAt   time.Time // zero if the key never expires
+	ContentType string
+	Time        time.Time
+}
+
+// ChangeHook is told about every committed write. It is called with the
+// store's write lock held, in the order the writes were committed, so it
+// must return quickly and must not call back into the store.
+type ChangeHook func(Change)
+
+// SetChangeHook installs a hook told about every Put, Delete, Rename,
+// DeletePrefix, Undelete and stale refresh once it is committed. A nil hook,
+// the default, removes it.
+func (kvs *KeyValueStore) SetChangeHook(hook ChangeHook) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	kvs.onChange = hook
+}
+
+// ChangeNotifier is implemented by engines that can report committed writes.
+type ChangeNotifier interface {
+	SetChangeHook(ChangeHook)
+}
+
+var _ ChangeNotifier = (*KeyValueStore)(nil)
+
+// notifyLocked reports a committed write of e under key to the change hook.
+// The write lock must be held.
+func (kvs *KeyValueStore) notifyLocked(op ChangeOp, key string, e *entry) {
+	if kvs.onChange == nil {
+		return
+	}
+	snap := e.snapshot(key)
+	change := Change{
+		Op:          op,
+		Key:         key,
+		Version:     snap.Version,
+		ExpiresAt:   snap.ExpiresAt,
+		ContentType: snap.ContentType,
+		Time:        time.Now(),
+	}
+	if op == ChangePut {
+		change.Value = snap.Value
+	}
+	kvs.onChange(change)
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ColdStore is a slower backing tier behind KeyValueStore. Puts without a TTL
+// are written through to it, and Gets that miss in memory fall back to it, so
+// the in-memory map acts as a cache in front of the cold tier.
+type ColdStore interface {
+	Put(key, value string) error
+	Get(key string) (string, bool, error)
+	Delete(key string) error
+}
+
+// ErrEmptyKey is returned for an empty key where one cannot be used.
+var ErrEmptyKey = errors.New("key must not be empty")
+
+// DirColdStore is a ColdStore that keeps one file per key in a directory.
+// Files are named by the SHA-256 of their key, so names have a fixed length
+// however long the key is, and hold the key followed by the value; a file
+// whose key does not match is treated as a miss. An empty key is rejected
+// with ErrEmptyKey.
+type DirColdStore struct {
+	dir string
+}
+
+// fileSuffix marks files in the current format. Files without it were named
+// by the hex encoding of their key and hold only the value.
+const fileSuffix = ".v"
+
+// NewDirColdStore creates a DirColdStore rooted at dir, creating it if needed.
+// Files written by earlier versions, named by the hex encoding of their key,
+// are converted to the current format.
+func NewDirColdStore(dir string) (*DirColdStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	d := &DirColdStore{dir: dir}
+	if err := d.upgrade(); err != nil {
+		return nil, fmt.Errorf("upgrade cold store %s: %w", dir, err)
+	}
+	return d, nil
+}
+
+// upgrade rewrites every file in the old hex-named format in the current one.
+func (d *DirColdStore) upgrade() error {
+	files, err := os.ReadDir(d.dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		key, err := hex.DecodeString(name)
+		if err != nil || len(key) == 0 {
+			continue // not ours
+		}
+		value, err := os.ReadFile(filepath.Join(d.dir, name))
+		if err != nil {
+			return err
+		}
+		if err := d.Put(string(key), string(value)); err != nil {
+			return err
+		}
+		if err := os.Remove(filepath.Join(d.dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// path returns the file for a key.
+func (d *DirColdStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+fileSuffix)
+}
+
+// Put writes the key's length, the key and the value to a temporary file and
+// renames it into place.
+func (d *DirColdStore) Put(key, value string) error {
+	if key == "" {
+		return ErrEmptyKey
+	}
+	tmp, err := os.CreateTemp(d.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	header := binary.AppendUvarint(nil, uint64(len(key)))
+	_, err = tmp.Write(header)
+	if err == nil {
+		_, err = tmp.WriteString(key)
+	}
+	if err == nil {
+		_, err = tmp.WriteString(value)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), d.path(key))
+}
+
+func (d *DirColdStore) Get(key string) (string, bool, error) {
+	if key == "" {
+		return "", false, nil
+	}
+	data, err := os.ReadFile(d.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	n, size := binary.Uvarint(data)
+	if size <= 0 || n > uint64(len(data)-size) {
+		return "", false, fmt.Errorf("cold store file for %q is corrupt", key)
+	}
+	data = data[size:]
+	if string(data[:n]) != key {
+		return "", false, nil // another key with the same hash
+	}
+	return string(data[n:]), true, nil
+}
+
+func (d *DirColdStore) Delete(key string) error {
+	if key == "" {
+		return nil
+	}
+	err := os.Remove(d.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,250 @@
+package store
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDirColdStoreRoundTrip(t *testing.T) {
+	d, err := NewDirColdStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []string{
+		"k",
+		strings.Repeat("long", 1000), // far past any file name length limit
+		"../../escape",
+		"\x00\xff",
+	}
+	for _, key := range keys {
+		if err := d.Put(key, "value of "+key); err != nil {
+			t.Fatalf("Put(%.20q): %v", key, err)
+		}
+	}
+	for _, key := range keys {
+		value, found, err := d.Get(key)
+		if err != nil || !found || value != "value of "+key {
+			t.Fatalf("Get(%.20q) = %.20q, %v, %v", key, value, found, err)
+		}
+		if err := d.Delete(key); err != nil {
+			t.Fatal(err)
+		}
+		if _, found, _ := d.Get(key); found {
+			t.Fatalf("Get(%.20q) found a deleted key", key)
+		}
+	}
+	if err := d.Put("", "v"); !errors.Is(err, ErrEmptyKey) {
+		t.Fatalf("Put(\"\") = %v, want ErrEmptyKey", err)
+	}
+}
+
+func TestDirColdStoreChecksKey(t *testing.T) {
+	d, err := NewDirColdStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stand in for a hash collision: the file for "b" holds "a".
+	if err := d.Put("a", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(d.path("a"), d.path("b")); err != nil {
+		t.Fatal(err)
+	}
+	if value, found, err := d.Get("b"); err != nil || found {
+		t.Fatalf("Get(b) = %q, %v, %v; want a miss", value, found, err)
+	}
+}
+
+func TestDirColdStoreUpgrade(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, hex.EncodeToString([]byte("key")))
+	if err := os.WriteFile(old, []byte("value"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDirColdStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, found, err := d.Get("key"); err != nil || !found || value != "value" {
+		t.Fatalf("Get(key) = %q, %v, %v after upgrade", value, found, err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatalf("old file still there after upgrade: %v", err)
+	}
+}
+
+// blockingCold is a ColdStore whose Puts of block, and Deletes of
+// blockDelete, wait until released.
+type blockingCold struct {
+	mu          sync.Mutex
+	data        map[string]string
+	block       string
+	blockDelete string
+	entered     chan struct{}
+	release     chan struct{}
+}
+
+func (c *blockingCold) Put(key, value string) error {
+	if key == c.block {
+		c.entered <- struct{}{}
+		<-c.release
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[key] = value
+	return nil
+}
+
+func (c *blockingCold) Get(key string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.data[key]
+	return value, ok, nil
+}
+
+func (c *blockingCold) Delete(key string) error {
+	if key == c.blockDelete {
+		c.entered <- struct{}{}
+		<-c.release
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.data, key)
+	return nil
+}
+
+// TestColdWritesDoNotBlockStore holds one key's cold write and checks the
+// rest of the store keeps working, while a second write to that key waits
+// its turn so both tiers end up with the last value.
+func TestColdWritesDoNotBlockStore(t *testing.T) {
+	cold := &blockingCold{
+		data:    make(map[string]string),
+		block:   "slow",
+		entered: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	kvs := NewKeyValueStore()
+	kvs.SetColdStore(cold)
+
+	first := make(chan error)
+	go func() { first <- kvs.Put("slow", "first") }()
+	<-cold.entered
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		kvs.Put("other", "v")
+		kvs.Get("other")
+		kvs.Delete("other")
+		kvs.Rename("missing", "elsewhere")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("other keys were blocked by a cold write")
+	}
+
+	second := make(chan error)
+	go func() { second <- kvs.Put("slow", "second") }()
+	select {
+	case err := <-second:
+		t.Fatalf("a second write to the key finished first: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(cold.release)
+	<-cold.entered
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-second; err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := kvs.Get("slow"); value != "second" {
+		t.Fatalf("memory holds %q, want second", value)
+	}
+	if value, _, _ := cold.Get("slow"); value != "second" {
+		t.Fatalf("cold tier holds %q, want second", value)
+	}
+}
+
+// TestColdDeletesDoNotBlockStore holds the cold delete made by each of
+// Touch, Delete and DeletePrefix and checks a Get of another key still runs.
+func TestColdDeletesDoNotBlockStore(t *testing.T) {
+	ops := map[string]func(*KeyValueStore){
+		"Touch":        func(kvs *KeyValueStore) { kvs.Touch("slow", time.Minute) },
+		"Delete":       func(kvs *KeyValueStore) { kvs.Delete("slow") },
+		"DeletePrefix": func(kvs *KeyValueStore) { kvs.DeletePrefix("slow") },
+	}
+	for name, op := range ops {
+		t.Run(name, func(t *testing.T) {
+			cold := &blockingCold{
+				data:        make(map[string]string),
+				blockDelete: "slow",
+				entered:     make(chan struct{}),
+				release:     make(chan struct{}),
+			}
+			kvs := NewKeyValueStore()
+			kvs.SetColdStore(cold)
+			kvs.Put("slow", "v")
+			kvs.Put("other", "v")
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				op(kvs)
+			}()
+			<-cold.entered
+
+			got := make(chan string)
+			go func() {
+				value, _ := kvs.Get("other")
+				got <- value
+			}()
+			select {
+			case value := <-got:
+				if value != "v" {
+					t.Fatalf("Get(other) = %q, want v", value)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Get of another key was blocked by %s's cold delete", name)
+			}
+			close(cold.release)
+			<-done
+			if _, found, _ := cold.Get("slow"); found {
+				t.Fatalf("%s left the key in the cold tier", name)
+			}
+		})
+	}
+}
+
+func TestColdWriteKeepsQuotaSlot(t *testing.T) {
+	cold := &blockingCold{
+		data:    make(map[string]string),
+		block:   "ns/slow",
+		entered: make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	kvs := NewKeyValueStore()
+	kvs.SetColdStore(cold)
+	kvs.SetQuotas(map[string]int{"ns/": 1})
+
+	slow := make(chan error)
+	go func() { slow <- kvs.Put("ns/slow", "v") }()
+	<-cold.entered
+	if err := kvs.Put("ns/other", "v"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Put into a namespace with its last slot reserved = %v, want ErrQuotaExceeded", err)
+	}
+	close(cold.release)
+	if err := <-slow; err != nil {
+		t.Fatal(err)
+	}
+	if usage := kvs.NamespaceUsage()["ns/"]; usage.Keys != 1 {
+		t.Fatalf("namespace holds %d keys, want 1", usage.Keys)
+	}
+}
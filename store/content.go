@@ -0,0 +1,29 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// ContentKeyPrefix starts every key derived by ContentKey, keeping
+// content-addressed keys apart from the rest of the keyspace.
+const ContentKeyPrefix = "sha256:"
+
+// ContentKey returns the key a value is stored under by PutContent.
+func ContentKey(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return ContentKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+// PutContent stores value under its content hash and returns that key, so
+// identical values are stored once. Putting content that is already stored
+// leaves it, and its version, as they are. Unlike Put it never overwrites.
+func (kvs *KeyValueStore) PutContent(value string) (string, error) {
+	key := ContentKey(value)
+	var absent uint64
+	if _, err := kvs.PutWithOptions(key, value, PutOptions{ExpectedVersion: &absent}); err != nil && !errors.Is(err, ErrVersionConflict) {
+		return "", err
+	}
+	return key, nil
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"io"
+	"runtime"
+	"time"
+)
+
+// StorageEngine is the storage a node serves from. KeyValueStore, the
+// in-memory map with an optional cold tier, is the default engine; others,
+// such as a memory-mapped file or an embedded LSM tree, can be plugged in
+// without the server knowing which one it runs on.
+type StorageEngine interface {
+	Get(key string) (string, bool)
+	Put(key string, value string) error
+	Delete(key string) error
+	Scan(prefix string, fn func(Entry) bool)
+	Len() int
+	Bytes() int64
+	Close() error
+
+	// Operations behind the richer RPCs. An engine that does not support
+	// one can return an error or report the key as missing.
+	GetWithMetadata(key string) (Entry, bool)
+	PutWithOptions(key string, value string, opts PutOptions) (uint64, error)
+	Touch(key string, ttl time.Duration) bool
+	Undelete(key string) (uint64, error)
+	Rename(oldKey, newKey string) bool
+	DeletePrefix(prefix string) int
+	DeleteExpired() int
+	FindByIndex(term string) []string
+	SoftDeleted() int
+	RecentlyDeleted(key string) bool
+}
+
+var _ StorageEngine = (*KeyValueStore)(nil)
+
+// Compactor is implemented by engines that can release memory held on
+// behalf of overwritten and deleted entries.
+type Compactor interface {
+	// Compact compacts if churn since the last compaction exceeds threshold
+	// times the number of entries, and reports whether it did.
+	Compact(threshold float64) bool
+}
+
+var _ Compactor = (*KeyValueStore)(nil)
+
+// compactChunk is how many entries Compact copies per hold of the write lock.
+const compactChunk = 1024
+
+// Compact rebuilds the in-memory map once the number of overwrites and
+// deletes since it was last rebuilt exceeds threshold times its size. Go maps
+// never shrink, so a map that has seen heavy churn keeps the buckets of its
+// peak size; copying the live entries into a fresh map lets that memory go.
+//
+// The copy is made compactChunk entries at a time, releasing the write lock
+// between chunks so other operations are held up for one chunk at most.
+// Keys inserted or removed in between are applied to the new map as well
+// (overwrites update entries in place, so both maps see them), and the new
+// map is swapped in once the copy is complete. Only one compaction runs at a
+// time; a concurrent call returns false.
+func (kvs *KeyValueStore) Compact(threshold float64) bool {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	if threshold <= 0 || kvs.compacting != nil || float64(kvs.churn) <= threshold*float64(len(kvs.data)) {
+		return false
+	}
+	churn := kvs.churn
+	kvs.compacting = make(map[string]*entry, len(kvs.data))
+	copied := 0
+	// Ranging over a map that is changed during the loop is well defined:
+	// removed keys not yet reached are skipped and added ones may or may not
+	// be visited, which the mirroring in setLocked and removeLocked covers.
+	for key, e := range kvs.data {
+		kvs.compacting[key] = e
+		if copied++; copied%compactChunk == 0 {
+			kvs.mu.Unlock()
+			runtime.Gosched() // let waiting writers in before relocking
+			kvs.mu.Lock()
+		}
+	}
+	kvs.data = kvs.compacting
+	kvs.compacting = nil
+	kvs.churn -= churn
+	return true
+}
+
+// Close stops any stale-while-revalidate refreshes, waiting for them to
+// return, and releases the store's cold tier if it holds any resources. The
+// store must not be used afterwards.
+func (kvs *KeyValueStore) Close() error {
+	kvs.stopRefreshes()
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	if closer, ok := kvs.cold.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
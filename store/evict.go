@@ -0,0 +1,69 @@
+package store
+
+import "time"
+
+// EvictReason says why an entry left the store.
+type EvictReason string
+
+const (
+	EvictExpired EvictReason = "expired" // its TTL ran out
+	EvictManual  EvictReason = "manual"  // Evict dropped it from memory
+	EvictDeleted EvictReason = "deleted" // Delete, DeletePrefix or Rename removed it
+)
+
+// Eviction is one entry leaving the store, with the value it held.
+type Eviction struct {
+	Key         string
+	Value       string
+	Version     uint64
+	ContentType string
+	Reason      EvictReason
+	Time        time.Time
+}
+
+// EvictionHook is told about every entry the store evicts. Like ChangeHook it
+// is called with the store's write lock held, so it must return quickly and
+// must not call back into the store.
+type EvictionHook func(Eviction)
+
+// SetEvictionHook installs a hook told once about every entry that expires
+// or is dropped with Evict, and, if deletes is true, every live key removed
+// by Delete, DeletePrefix or Rename. An expired entry is reported when it is
+// reclaimed: by DeleteExpired, or by a write or delete that finds it first.
+// Entries still served as stale are not reported until the stale window has
+// passed. A nil hook, the default, removes it.
+func (kvs *KeyValueStore) SetEvictionHook(hook EvictionHook, deletes bool) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	kvs.onEvict = hook
+	kvs.evictDeletes = deletes
+}
+
+// EvictionNotifier is implemented by engines that can report evictions.
+type EvictionNotifier interface {
+	SetEvictionHook(hook EvictionHook, deletes bool)
+}
+
+var _ EvictionNotifier = (*KeyValueStore)(nil)
+
+// evictedLocked reports that e, stored under key, is leaving the store. The
+// write lock must be held.
+func (kvs *KeyValueStore) evictedLocked(key string, e *entry, reason EvictReason) {
+	if kvs.onEvict == nil || (reason == EvictDeleted && !kvs.evictDeletes) {
+		return
+	}
+	kvs.onEvict(Eviction{
+		Key:         key,
+		Value:       e.value,
+		Version:     e.version,
+		ContentType: e.contentType,
+		Reason:      reason,
+		Time:        time.Now(),
+	})
+}
+
+// expiredLocked reports whether e is an entry whose TTL has run out and that
+// is no longer served as stale. The write lock must be held.
+func (kvs *KeyValueStore) expiredLocked(e *entry, now int64) bool {
+	return e.deleted == 0 && e.expiry != nil && e.expiry.expired(now) && !kvs.staleLocked(e, now)
+}
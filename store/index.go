@@ -0,0 +1,134 @@
+package store
+
+import (
+	"sort"
+	"time"
+)
+
+// IndexFunc extracts the secondary index terms of a value. It must be
+// deterministic and must not call back into the store.
+type IndexFunc func(value string) []string
+
+// index maps secondary index terms to the keys whose values produce them.
+type index struct {
+	extract IndexFunc
+	terms   map[string]map[string]bool
+}
+
+func newIndex(extract IndexFunc) *index {
+	return &index{extract: extract, terms: make(map[string]map[string]bool)}
+}
+
+func (ix *index) add(key, value string) {
+	for _, term := range ix.extract(value) {
+		keys, ok := ix.terms[term]
+		if !ok {
+			keys = make(map[string]bool)
+			ix.terms[term] = keys
+		}
+		keys[key] = true
+	}
+}
+
+func (ix *index) remove(key, value string) {
+	for _, term := range ix.extract(value) {
+		if keys, ok := ix.terms[term]; ok {
+			delete(keys, key)
+			if len(keys) == 0 {
+				delete(ix.terms, term)
+			}
+		}
+	}
+}
+
+// SetIndex enables a secondary index over values held in memory, built from
+// the current contents and kept up to date by every write. Passing nil
+// disables it. Keys only present in the cold tier are indexed once loaded.
+func (kvs *KeyValueStore) SetIndex(extract IndexFunc) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	if extract == nil {
+		kvs.index = nil
+		return
+	}
+	kvs.index = newIndex(extract)
+	for key, e := range kvs.data {
+		kvs.index.add(key, e.value)
+	}
+}
+
+// FindByIndex returns the live keys whose values produce term, sorted. It
+// returns nil if no index is configured.
+func (kvs *KeyValueStore) FindByIndex(term string) []string {
+	kvs.mu.RLock()
+	defer kvs.mu.RUnlock()
+	if kvs.index == nil {
+		return nil
+	}
+	var keys []string
+	now := time.Now().UnixNano()
+	for key := range kvs.index.terms[term] {
+		if kvs.data[key].live(now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// setLocked stores e under key, keeping the index, churn, byte and quota
+// counts in step, forgets that key was recently deleted, and returns the
+// stored entry. An existing entry is overwritten in place rather than
+// replaced, so rewriting a key does not allocate. The write lock must be
+// held.
+func (kvs *KeyValueStore) setLocked(key string, e entry) *entry {
+	delete(kvs.recentDeletes, key)
+	old, ok := kvs.data[key]
+	if ok && kvs.onEvict != nil && kvs.expiredLocked(old, time.Now().UnixNano()) {
+		kvs.evictedLocked(key, old, EvictExpired)
+	}
+	if kvs.index != nil {
+		if ok {
+			kvs.index.remove(key, old.value)
+		}
+		kvs.index.add(key, e.value)
+	}
+	if ok {
+		kvs.churn++
+		kvs.bytes += int64(len(e.value) - len(old.value))
+		*old = e
+		return old
+	}
+	if q := kvs.quotaLocked(key); q != nil {
+		q.keys++
+	}
+	stored := new(entry)
+	*stored = e
+	kvs.data[key] = stored
+	if kvs.compacting != nil {
+		kvs.compacting[key] = stored
+	}
+	kvs.bytes += int64(len(key) + len(e.value))
+	return stored
+}
+
+// removeLocked drops key from memory, keeping the index, churn, byte and
+// quota counts in step. The write lock must be held.
+func (kvs *KeyValueStore) removeLocked(key string) {
+	old, ok := kvs.data[key]
+	if !ok {
+		return
+	}
+	kvs.churn++
+	kvs.bytes -= int64(len(key) + len(old.value))
+	if q := kvs.quotaLocked(key); q != nil {
+		q.keys--
+	}
+	if kvs.index != nil {
+		kvs.index.remove(key, old.value)
+	}
+	delete(kvs.data, key)
+	if kvs.compacting != nil {
+		delete(kvs.compacting, key)
+	}
+}
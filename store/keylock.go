@@ -0,0 +1,58 @@
+package store
+
+import "sync"
+
+// keyLocks orders the cold tier writes of each key. Writes to the cold tier
+// are made without holding the store's lock, so two writes to the same key
+// could otherwise reach the cold tier in one order and memory in the other.
+// Keys share a fixed number of mutexes by hash.
+type keyLocks [64]sync.Mutex
+
+// of returns the mutex guarding key.
+func (kl *keyLocks) of(key string) *sync.Mutex {
+	return &kl[kl.index(key)]
+}
+
+// index returns the position of key's mutex, by an FNV-1a hash computed
+// inline so that locking a key does not allocate.
+func (kl *keyLocks) index(key string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return int(h % uint32(len(kl)))
+}
+
+// lockPair locks the mutexes of two keys in a fixed order, so concurrent
+// pairs cannot deadlock, and returns a function unlocking them.
+func (kl *keyLocks) lockPair(a, b string) func() {
+	i, j := kl.index(a), kl.index(b)
+	if i == j {
+		kl[i].Lock()
+		return kl[i].Unlock
+	}
+	if j < i {
+		i, j = j, i
+	}
+	ma, mb := &kl[i], &kl[j]
+	ma.Lock()
+	mb.Lock()
+	return func() {
+		mb.Unlock()
+		ma.Unlock()
+	}
+}
+
+// lockAll locks every mutex, for operations touching an unknown set of keys.
+func (kl *keyLocks) lockAll() {
+	for i := range kl {
+		kl[i].Lock()
+	}
+}
+
+func (kl *keyLocks) unlockAll() {
+	for i := len(kl) - 1; i >= 0; i-- {
+		kl[i].Unlock()
+	}
+}
@@ -1,39 +1,818 @@
 package store
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+var (
+	// ErrKeyNotFound is returned when an operation targets a key that is not in the store.
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrValueTooLarge is returned when a value exceeds the store's maximum value size.
+	ErrValueTooLarge = errors.New("value too large")
+	// ErrVersionConflict is returned when a conditional write's expected version does not match.
+	ErrVersionConflict = errors.New("version conflict")
+	// ErrExpiryInPast is returned when a write's absolute expiry has already passed.
+	ErrExpiryInPast = errors.New("expiry time is in the past")
+)
+
+// VersionConflictError reports the version a key actually had when a
+// conditional write was rejected. It matches ErrVersionConflict with errors.Is.
+type VersionConflictError struct {
+	Key     string
+	Current uint64 // 0 if the key does not exist
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s: key %q is at version %d", ErrVersionConflict, e.Key, e.Current)
+}
+
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
 type KeyValueStore struct {
-	data map[string]string
-	mu   sync.RWMutex
+	data          map[string]*entry
+	seq           uint64 // last version handed out; versions are unique across the store
+	maxValueSize  int
+	cold          ColdStore
+	softDelete    time.Duration    // how long deleted keys can be restored; 0 deletes immediately
+	index         *index           // nil unless SetIndex was called
+	churn         int              // overwrites and deletes since the map was last rebuilt
+	bytes         int64            // total length of the keys and values in data
+	staleWindow   time.Duration    // how long expired keys stay readable, see SetStaleWhileRevalidate
+	loader        Loader           // refreshes stale keys; nil serves them without refreshing
+	deleteGrace   time.Duration    // how long deleted keys are remembered, see SetDeleteGrace
+	recentDeletes map[string]int64 // key -> unix nanoseconds it was deleted at
+	skipExpired   bool             // drop writes whose ExpireAt has passed instead of failing them
+	transform     WriteTransform   // applied to values on write; nil stores them as given
+	quotas        []*quota         // per-namespace key limits, longest prefix first
+	onChange      ChangeHook       // told about committed writes; nil if unset
+	onEvict       EvictionHook     // told about evicted entries; nil if unset
+	evictDeletes  bool             // also tell onEvict about deleted keys
+	ttlJitter     float64          // fraction of a fixed TTL randomly taken off it, see SetTTLJitter
+	mu            sync.RWMutex
+
+	refreshMu     sync.Mutex
+	refreshing    map[string]bool    // keys with a refresh in flight
+	refreshClosed bool               // set by Close; no refresh starts after it
+	refreshCtx    context.Context    // passed to the loader, cancelled by Close
+	refreshStop   context.CancelFunc // cancels refreshCtx
+	refreshes     sync.WaitGroup     // refresh goroutines still running
+
+	compacting map[string]*entry // map Compact is building; nil unless a compaction is running
+	coldLocks  keyLocks          // held per key across cold tier writes, see withoutLock
+}
+
+// entry is a stored value and its metadata.
+type entry struct {
+	value       string
+	version     uint64
+	modified    time.Time // zero if unknown, e.g. loaded from the cold tier
+	expiry      *expiry   // nil if the key never expires
+	deleted     int64     // unix nanoseconds it was soft-deleted at; 0 if it was not
+	contentType string    // as declared by the writer; empty if unknown, e.g. loaded from the cold tier
+}
+
+// Entry is a point-in-time copy of one stored key.
+type Entry struct {
+	Key        string
+	Value      string
+	Version    uint64
+	Modified   time.Time     // when the value was last written; zero if unknown
+	ExpiresAt  time.Time     // zero if the key never expires
+	SlidingTTL time.Duration // if non-zero, each read pushes ExpiresAt out by this much
+	// ContentType is the encoding the writer declared for Value, such as
+	// "application/json". The store never interprets it.
+	ContentType string
+}
+
+func (e *entry) snapshot(key string) Entry {
+	out := Entry{Key: key, Value: e.value, Version: e.version, Modified: e.modified, ContentType: e.contentType}
+	if e.expiry != nil {
+		out.ExpiresAt = time.Unix(0, e.expiry.deadline.Load())
+		if e.expiry.sliding {
+			out.SlidingTTL = e.expiry.ttl
+		}
+	}
+	return out
+}
+
+// live reports whether the entry is still readable at now.
+func (e *entry) live(now int64) bool {
+	return e.deleted == 0 && (e.expiry == nil || !e.expiry.expired(now))
+}
+
+// PutOptions controls how PutWithOptions writes a key.
+type PutOptions struct {
+	TTL        time.Duration // 0 means the key never expires
+	SlidingTTL bool          // reset the TTL on every Get
+	// ExpireAt, if set, is an absolute expiry used instead of TTL. The
+	// expiry is fixed; SlidingTTL does not apply to it.
+	ExpireAt time.Time
+	// ContentType is recorded with the value and returned with it; see
+	// Entry.ContentType.
+	ContentType string
+	// ExpectedVersion, if set, makes the write conditional on the key's
+	// current version. 0 means the key must not exist.
+	ExpectedVersion *uint64
+}
+
+// expiry tracks when a key stops being readable. The deadline is atomic so
+// that a sliding Get can push it forward while holding only the read lock.
+type expiry struct {
+	deadline atomic.Int64 // unix nanoseconds
+	ttl      time.Duration
+	sliding  bool
+}
+
+func newExpiry(ttl time.Duration, sliding bool) *expiry {
+	e := &expiry{ttl: ttl, sliding: sliding}
+	e.deadline.Store(time.Now().Add(ttl).UnixNano())
+	return e
+}
+
+// expired reports whether the deadline has passed at now.
+func (e *expiry) expired(now int64) bool {
+	return now >= e.deadline.Load()
+}
+
+// refresh moves a sliding deadline to now+ttl. Concurrent readers race on the
+// same atomic, so only ever move it forward.
+func (e *expiry) refresh(now int64) {
+	next := now + int64(e.ttl)
+	for {
+		cur := e.deadline.Load()
+		if cur >= next || e.deadline.CompareAndSwap(cur, next) {
+			return
+		}
+	}
 }
 
 // NewKeyValueStore creates a new KeyValueStore
 func NewKeyValueStore() *KeyValueStore {
+	return NewKeyValueStoreWithCapacity(0)
+}
+
+// NewKeyValueStoreWithCapacity creates a KeyValueStore whose map is sized up
+// front for about hint keys, so a bulk load of a known size does not rehash
+// the map repeatedly as it grows. The hint is not a limit: the store grows
+// past it as needed, and it does nothing to bound memory.
+func NewKeyValueStoreWithCapacity(hint int) *KeyValueStore {
+	ctx, stop := context.WithCancel(context.Background())
 	return &KeyValueStore{
-		data: make(map[string]string),
+		data:        make(map[string]*entry, hint),
+		refreshCtx:  ctx,
+		refreshStop: stop,
 	}
 }
 
+// SetMaxValueSize limits the size in bytes of values accepted by Put. Zero
+// means no limit.
+func (kvs *KeyValueStore) SetMaxValueSize(n int) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	kvs.maxValueSize = n
+}
+
+// SetColdStore configures a cold tier that Puts are written through to and
+// Get misses are served from. Keys with a TTL are kept in memory only.
+func (kvs *KeyValueStore) SetColdStore(cs ColdStore) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	kvs.cold = cs
+}
+
+// SetSoftDelete makes Delete keep deleted keys for retention, during which
+// Undelete can restore them. They are purged by DeleteExpired once retention
+// has passed. Zero, the default, deletes keys immediately.
+func (kvs *KeyValueStore) SetSoftDelete(retention time.Duration) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	kvs.softDelete = retention
+}
 
 // Put adds a key-value pair to the store
-func (kvs *KeyValueStore) Put(key string, value string) {
+func (kvs *KeyValueStore) Put(key string, value string) error {
+	_, err := kvs.PutWithOptions(key, value, PutOptions{})
+	return err
+}
+
+// PutString adds a key-value pair to the store. It is the same as Put.
+func (kvs *KeyValueStore) PutString(key string, value string) error {
+	return kvs.Put(key, value)
+}
+
+// PutBytes adds a key-value pair to the store. Values are stored as
+// immutable byte strings, so a value written with PutBytes reads back
+// byte-for-byte with GetBytes, and as the same bytes from Get.
+func (kvs *KeyValueStore) PutBytes(key string, value []byte) error {
+	return kvs.Put(key, string(value))
+}
+
+// PutWithTTL adds a key-value pair that expires ttl after it was written, or
+// a little earlier if TTL jitter is set
+func (kvs *KeyValueStore) PutWithTTL(key string, value string, ttl time.Duration) error {
+	_, err := kvs.PutWithOptions(key, value, PutOptions{TTL: ttl})
+	return err
+}
+
+// PutWithSlidingTTL adds a key-value pair that expires once it has gone
+// unread for ttl. Every successful Get pushes the expiry out to now+ttl.
+func (kvs *KeyValueStore) PutWithSlidingTTL(key string, value string, ttl time.Duration) error {
+	_, err := kvs.PutWithOptions(key, value, PutOptions{TTL: ttl, SlidingTTL: true})
+	return err
+}
+
+// SetTTLJitter makes fixed TTLs expire up to fraction of their length early,
+// chosen at random per write, so keys written together with the same TTL
+// do not all expire at the same moment. Jitter only ever shortens a TTL, so
+// a key never outlives the TTL it was written with. Sliding TTLs and
+// absolute expiry times are left exact. fraction is clamped to [0, 1); 0,
+// the default, disables it.
+func (kvs *KeyValueStore) SetTTLJitter(fraction float64) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	kvs.ttlJitter = min(max(fraction, 0), 0.99)
+}
+
+// PutWithExpireAt adds a key-value pair that expires at expireAt. If
+// expireAt has already passed the write fails with ErrExpiryInPast, or is
+// skipped if SetSkipExpiredWrites is on.
+func (kvs *KeyValueStore) PutWithExpireAt(key string, value string, expireAt time.Time) error {
+	_, err := kvs.PutWithOptions(key, value, PutOptions{ExpireAt: expireAt})
+	return err
+}
+
+// SetSkipExpiredWrites controls writes whose ExpireAt has already passed. By
+// default they fail with ErrExpiryInPast; with skip set they succeed without
+// writing anything and report version 0.
+func (kvs *KeyValueStore) SetSkipExpiredWrites(skip bool) {
 	kvs.mu.Lock()
 	defer kvs.mu.Unlock()
-	kvs.data[key] = value
+	kvs.skipExpired = skip
+}
+
+// PutIfVersion writes a key only if its current version is expected, where 0
+// means the key must not exist. It returns the new version, or a
+// *VersionConflictError carrying the current version.
+func (kvs *KeyValueStore) PutIfVersion(key string, value string, expected uint64) (uint64, error) {
+	return kvs.PutWithOptions(key, value, PutOptions{ExpectedVersion: &expected})
+}
+
+// PutWithOptions writes a key-value pair and returns the version assigned to
+// it. The value goes through the write transform first, if one is set.
+func (kvs *KeyValueStore) PutWithOptions(key string, value string, opts PutOptions) (uint64, error) {
+	value, err := kvs.transformValue(key, value)
+	if err != nil {
+		return 0, err
+	}
+	keyLock := kvs.coldLocks.of(key)
+	keyLock.Lock()
+	defer keyLock.Unlock()
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	if kvs.maxValueSize > 0 && len(value) > kvs.maxValueSize {
+		return 0, fmt.Errorf("%w: %d bytes exceeds limit of %d", ErrValueTooLarge, len(value), kvs.maxValueSize)
+	}
+	if err := kvs.checkQuotaLocked(key); err != nil {
+		return 0, err
+	}
+	if opts.ExpectedVersion != nil {
+		var current uint64
+		if e := kvs.lookupLocked(key); e != nil {
+			current = e.version
+		}
+		if current != *opts.ExpectedVersion {
+			return 0, &VersionConflictError{Key: key, Current: current}
+		}
+	}
+
+	var exp *expiry
+	switch {
+	case !opts.ExpireAt.IsZero():
+		ttl := time.Until(opts.ExpireAt)
+		if ttl <= 0 {
+			if kvs.skipExpired {
+				return 0, nil
+			}
+			return 0, fmt.Errorf("%w: %s", ErrExpiryInPast, opts.ExpireAt.Format(time.RFC3339Nano))
+		}
+		exp = &expiry{ttl: ttl}
+		exp.deadline.Store(opts.ExpireAt.UnixNano())
+	case opts.TTL > 0 && !opts.SlidingTTL && kvs.ttlJitter > 0:
+		exp = newExpiry(opts.TTL-time.Duration(rand.Float64()*kvs.ttlJitter*float64(opts.TTL)), false)
+	case opts.TTL > 0:
+		exp = newExpiry(opts.TTL, opts.SlidingTTL)
+	}
+	if cold := kvs.cold; cold != nil {
+		// An expiring key must not outlive its TTL in the cold tier.
+		reserved := kvs.reserveLocked(key)
+		err := kvs.withoutLock(func() error {
+			if exp != nil {
+				return cold.Delete(key)
+			}
+			return cold.Put(key, value)
+		})
+		reserved.release()
+		if err != nil {
+			return 0, err
+		}
+	}
+	kvs.seq++
+	stored := kvs.setLocked(key, entry{
+		value:       value,
+		version:     kvs.seq,
+		modified:    kvs.modifiedLocked(key),
+		expiry:      exp,
+		contentType: opts.ContentType,
+	})
+	kvs.notifyLocked(ChangePut, key, stored)
+	return kvs.seq, nil
+}
+
+// withoutLock runs fn, a cold tier write, with the write lock released so
+// that slow cold I/O does not hold up the rest of the store, and takes the
+// lock back before returning. The caller must hold the write lock and the
+// coldLocks of every key fn writes, so the key's cold copy and its entry in
+// memory are changed in the same order by every writer. Anything checked
+// before the lock was released may have changed when it returns, except
+// what only writers of those keys can change.
+func (kvs *KeyValueStore) withoutLock(fn func() error) error {
+	kvs.mu.Unlock()
+	defer kvs.mu.Lock()
+	return fn()
+}
+
+// restoreCold puts back the cold copy key had before a failed write: value
+// if prev, its entry at the time, was held without a TTL, and none
+// otherwise. The lock must not be held.
+func restoreCold(cold ColdStore, key string, prev *entry, value string) error {
+	if prev != nil && prev.expiry == nil {
+		return cold.Put(key, value)
+	}
+	return cold.Delete(key)
+}
+
+// modifiedLocked returns the last-modified time for a write to key happening
+// now. It never moves backwards for a key, even if the wall clock is stepped
+// back between two writes. The write lock must be held.
+func (kvs *KeyValueStore) modifiedLocked(key string) time.Time {
+	now := time.Now().Round(0) // drop the monotonic reading, it is meaningless to clients
+	if old, ok := kvs.data[key]; ok && now.Before(old.modified) {
+		return old.modified
+	}
+	return now
+}
+
+// lookupLocked returns the live entry for a key, loading it from the cold tier
+// on a memory miss, or nil if there is none. The write lock must be held.
+// Cold tier errors are treated as a miss.
+func (kvs *KeyValueStore) lookupLocked(key string) *entry {
+	if e, exists := kvs.data[key]; exists {
+		if e.live(time.Now().UnixNano()) {
+			return e
+		}
+		return nil
+	}
+	if kvs.cold == nil {
+		return nil
+	}
+	value, found, err := kvs.cold.Get(key)
+	if err != nil || !found {
+		return nil
+	}
+	kvs.seq++
+	return kvs.setLocked(key, entry{value: value, version: kvs.seq})
 }
 
 func (kvs *KeyValueStore) Get(key string) (string, bool) {
+	value, _, found := kvs.GetWithVersion(key)
+	return value, found
+}
+
+// GetString retrieves a value by key. It is the same as Get.
+func (kvs *KeyValueStore) GetString(key string) (string, bool) {
+	return kvs.Get(key)
+}
+
+// GetBytes retrieves a value by key as a copy of its bytes.
+func (kvs *KeyValueStore) GetBytes(key string) ([]byte, bool) {
+	value, found := kvs.Get(key)
+	if !found {
+		return nil, false
+	}
+	return []byte(value), true
+}
+
+// GetWithVersion retrieves a value along with its current version.
+func (kvs *KeyValueStore) GetWithVersion(key string) (string, uint64, bool) {
+	e, found := kvs.GetWithMetadata(key)
+	return e.Value, e.Version, found
+}
+
+// GetWithMetadata retrieves a key's value along with its version, last
+// modification time and expiry.
+func (kvs *KeyValueStore) GetWithMetadata(key string) (Entry, bool) {
+	e, found, cached := kvs.getCached(key)
+	if cached {
+		return e, found
+	}
+	return kvs.getCold(key)
+}
+
+// getCached looks a key up in memory. cached is false if the key is not in
+// memory at all, as opposed to present but expired.
+func (kvs *KeyValueStore) getCached(key string) (e Entry, found bool, cached bool) {
 	kvs.mu.RLock()
 	defer kvs.mu.RUnlock()
-	value, exists := kvs.data[key]
-	return value,  exists
+	stored, exists := kvs.data[key]
+	if !exists {
+		return Entry{}, false, false
+	}
+	if stored.deleted != 0 {
+		return Entry{}, false, true
+	}
+	if stored.expiry != nil {
+		now := time.Now().UnixNano()
+		if kvs.staleLocked(stored, now) {
+			kvs.revalidate(key, stored.version)
+			return stored.snapshot(key), true, true
+		}
+		if stored.expiry.expired(now) {
+			return Entry{}, false, true
+		}
+		if stored.expiry.sliding {
+			stored.expiry.refresh(now)
+		}
+	}
+	return stored.snapshot(key), true, true
 }
 
-func (kvs *KeyValueStore) Delete(key string) {
+// getCold serves a memory miss from the cold tier and caches the result.
+// The write lock is held across the cold read so a concurrent Delete cannot
+// be undone by caching a value it already removed.
+func (kvs *KeyValueStore) getCold(key string) (Entry, bool) {
 	kvs.mu.Lock()
 	defer kvs.mu.Unlock()
-	delete(kvs.data, key)
+	e := kvs.lookupLocked(key)
+	if e == nil {
+		return Entry{}, false
+	}
+	return e.snapshot(key), true
+}
+
+// Touch resets the TTL of an existing key to ttl from now without reading
+// or rewriting its value, and reports whether it did. A sliding key stays
+// sliding with the new ttl. It is a no-op returning false for missing or
+// expired keys and for a non-positive ttl.
+func (kvs *KeyValueStore) Touch(key string, ttl time.Duration) bool {
+	if ttl <= 0 {
+		return false
+	}
+	keyLock := kvs.coldLocks.of(key)
+	keyLock.Lock()
+	defer keyLock.Unlock()
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	e := kvs.lookupLocked(key)
+	if e == nil {
+		return false
+	}
+	if cold := kvs.cold; cold != nil && e.expiry == nil {
+		// The key is gaining a TTL, so it must leave the cold tier.
+		held := *e
+		if err := kvs.withoutLock(func() error { return cold.Delete(key) }); err != nil {
+			return false
+		}
+		// If the key was evicted in the meantime, the cold copy just deleted
+		// was its only one, so it is held in memory again instead.
+		if e = kvs.data[key]; e == nil {
+			e = kvs.setLocked(key, held)
+		}
+	}
+	sliding := e.expiry != nil && e.expiry.sliding
+	e.expiry = newExpiry(ttl, sliding)
+	return true
 }
 
+// Evict drops a key from memory only. With a cold store configured the key
+// remains readable and is reloaded on the next Get.
+func (kvs *KeyValueStore) Evict(key string) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	if e, ok := kvs.data[key]; ok {
+		now := time.Now().UnixNano()
+		if e.live(now) || kvs.staleLocked(e, now) {
+			kvs.evictedLocked(key, e, EvictManual)
+		} else if e.deleted == 0 {
+			kvs.evictedLocked(key, e, EvictExpired)
+		}
+	}
+	kvs.removeLocked(key)
+}
+
+// Delete removes a key from the store, returning ErrKeyNotFound if it was
+// absent. With soft deletes enabled the key only becomes invisible and can be
+// restored with Undelete until the retention window has passed.
+func (kvs *KeyValueStore) Delete(key string) error {
+	keyLock := kvs.coldLocks.of(key)
+	keyLock.Lock()
+	defer keyLock.Unlock()
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	e := kvs.lookupLocked(key)
+	if cold := kvs.cold; cold != nil {
+		if err := kvs.withoutLock(func() error { return cold.Delete(key) }); err != nil {
+			return err
+		}
+		// The key may have been evicted while the lock was released, and even
+		// loaded back from its cold copy. Go on with the entry held now,
+		// putting it back if it is gone so a soft delete can be undone.
+		if e != nil {
+			if current, ok := kvs.data[key]; ok {
+				e = current
+			} else {
+				e = kvs.setLocked(key, *e)
+			}
+		}
+	}
+	if e == nil {
+		// Drop an expired entry, but leave a soft-deleted one restorable.
+		if old, ok := kvs.data[key]; ok && old.deleted == 0 {
+			kvs.evictedLocked(key, old, EvictExpired)
+			kvs.removeLocked(key)
+		}
+		return ErrKeyNotFound
+	}
+	kvs.markDeletedLocked(key)
+	kvs.notifyLocked(ChangeDelete, key, e)
+	kvs.evictedLocked(key, e, EvictDeleted)
+	if kvs.softDelete > 0 {
+		e.deleted = time.Now().UnixNano()
+		return nil
+	}
+	kvs.removeLocked(key)
+	return nil
+}
+
+// Undelete restores a soft-deleted key within its retention window and
+// returns its new version. It returns ErrKeyNotFound if the key is not
+// soft-deleted, its window has passed, or its TTL ran out in the meantime.
+func (kvs *KeyValueStore) Undelete(key string) (uint64, error) {
+	keyLock := kvs.coldLocks.of(key)
+	keyLock.Lock()
+	defer keyLock.Unlock()
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	e, exists := kvs.data[key]
+	now := time.Now().UnixNano()
+	if !exists || e.deleted == 0 || now-e.deleted >= int64(kvs.softDelete) {
+		return 0, ErrKeyNotFound
+	}
+	if e.expiry != nil && e.expiry.expired(now) {
+		return 0, ErrKeyNotFound
+	}
+	if cold := kvs.cold; cold != nil && e.expiry == nil {
+		value := e.value
+		if err := kvs.withoutLock(func() error { return cold.Put(key, value) }); err != nil {
+			return 0, err
+		}
+		// DeleteExpired may have purged the key in the meantime; its cold
+		// copy was deleted along with it, so take the restored one back.
+		if current, ok := kvs.data[key]; !ok || current != e || e.deleted == 0 {
+			kvs.withoutLock(func() error { return cold.Delete(key) })
+			return 0, ErrKeyNotFound
+		}
+	}
+	kvs.seq++
+	e.deleted = 0
+	e.version = kvs.seq
+	delete(kvs.recentDeletes, key)
+	kvs.notifyLocked(ChangePut, key, e)
+	return e.version, nil
+}
+
+// Rename atomically moves the value of oldKey to newKey, replacing anything
+// stored under newKey, and deletes oldKey the way Delete would. The value
+// keeps its TTL and gets a new version. It reports whether oldKey existed;
+// it also returns false, leaving both keys untouched, if the cold tier
+// could not be updated or newKey's namespace is at its quota.
+func (kvs *KeyValueStore) Rename(oldKey, newKey string) bool {
+	defer kvs.coldLocks.lockPair(oldKey, newKey)()
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	e := kvs.lookupLocked(oldKey)
+	if e == nil {
+		return false
+	}
+	if oldKey == newKey {
+		return true
+	}
+	// The old key only frees its slot if it is removed rather than kept
+	// soft-deleted, and only in its own namespace.
+	checkQuota := kvs.softDelete > 0 || kvs.quotaLocked(oldKey) != kvs.quotaLocked(newKey)
+	if checkQuota && kvs.checkQuotaLocked(newKey) != nil {
+		return false
+	}
+
+	var exp *expiry
+	if e.expiry != nil {
+		exp = &expiry{ttl: e.expiry.ttl, sliding: e.expiry.sliding}
+		exp.deadline.Store(e.expiry.deadline.Load())
+	}
+	if cold := kvs.cold; cold != nil {
+		value := e.value
+		prev := kvs.lookupLocked(newKey)
+		var prevValue string
+		if prev != nil {
+			prevValue = prev.value
+		}
+		var reserved reservation
+		if checkQuota {
+			reserved = kvs.reserveLocked(newKey)
+		}
+		err := kvs.withoutLock(func() error {
+			var err error
+			if exp != nil {
+				err = cold.Delete(newKey)
+			} else {
+				err = cold.Put(newKey, value)
+			}
+			if err == nil {
+				err = cold.Delete(oldKey)
+			}
+			if err != nil {
+				// The writes may have gone part way.
+				restoreCold(cold, newKey, prev, prevValue)
+				restoreCold(cold, oldKey, e, value)
+			}
+			return err
+		})
+		reserved.release()
+		if err != nil {
+			return false
+		}
+	}
+	kvs.seq++
+	stored := kvs.setLocked(newKey, entry{
+		value:       e.value,
+		version:     kvs.seq,
+		modified:    kvs.modifiedLocked(newKey),
+		expiry:      exp,
+		contentType: e.contentType,
+	})
+	kvs.notifyLocked(ChangePut, newKey, stored)
+	kvs.notifyLocked(ChangeDelete, oldKey, e)
+	kvs.evictedLocked(oldKey, e, EvictDeleted)
+	if kvs.softDelete > 0 {
+		e.deleted = time.Now().UnixNano()
+	} else {
+		kvs.removeLocked(oldKey)
+	}
+	return true
+}
+
+// SoftDeleted returns how many soft-deleted keys are waiting to be purged.
+func (kvs *KeyValueStore) SoftDeleted() int {
+	kvs.mu.RLock()
+	defer kvs.mu.RUnlock()
+	n := 0
+	for _, e := range kvs.data {
+		if e.deleted != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// DeletePrefix removes every key with the given prefix held in memory,
+// along with its cold copy, and returns how many live keys were removed.
+// Keys only present in the cold tier are not visited.
+func (kvs *KeyValueStore) DeletePrefix(prefix string) int {
+	kvs.coldLocks.lockAll()
+	defer kvs.coldLocks.unlockAll()
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	var keys []string
+	for key := range kvs.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	if cold := kvs.cold; cold != nil {
+		kvs.withoutLock(func() error {
+			deleted := keys[:0]
+			for _, key := range keys {
+				// A key whose cold copy could not be deleted is kept in memory
+				// rather than let the cold copy resurface.
+				if cold.Delete(key) == nil {
+					deleted = append(deleted, key)
+				}
+			}
+			keys = deleted
+			return nil
+		})
+	}
+	now := time.Now().UnixNano()
+	removed := 0
+	for _, key := range keys {
+		e, ok := kvs.data[key]
+		if !ok {
+			continue // evicted while the lock was released; its cold copy is gone too
+		}
+		if e.live(now) {
+			removed++
+			kvs.notifyLocked(ChangeDelete, key, e)
+			kvs.evictedLocked(key, e, EvictDeleted)
+		} else if e.deleted == 0 {
+			kvs.evictedLocked(key, e, EvictExpired)
+		}
+		kvs.removeLocked(key)
+	}
+	return removed
+}
+
+// Scan calls fn for every live key with the given prefix, in no particular
+// order, until fn returns false. The matching entries are copied under the
+// read lock first and fn is called after it is released, so fn sees a
+// point-in-time view, may take as long as it likes and may write to the
+// store without blocking or disturbing other writers. The copy costs one
+// Entry per matching key; values are shared rather than copied, but a scan
+// over a very large store still holds every matching Entry at once. Keys only
+// present in the cold tier are not visited.
+func (kvs *KeyValueStore) Scan(prefix string, fn func(Entry) bool) {
+	for _, e := range kvs.snapshot(prefix) {
+		if !fn(e) {
+			return
+		}
+	}
+}
+
+// snapshot copies every live entry with the given prefix.
+func (kvs *KeyValueStore) snapshot(prefix string) []Entry {
+	kvs.mu.RLock()
+	defer kvs.mu.RUnlock()
+	now := time.Now().UnixNano()
+	entries := []Entry{}
+	for key, e := range kvs.data {
+		if strings.HasPrefix(key, prefix) && e.live(now) {
+			entries = append(entries, e.snapshot(key))
+		}
+	}
+	return entries
+}
+
+// Len returns the number of live keys held in memory.
+func (kvs *KeyValueStore) Len() int {
+	kvs.mu.RLock()
+	defer kvs.mu.RUnlock()
+	now := time.Now().UnixNano()
+	n := 0
+	for _, e := range kvs.data {
+		if e.live(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// Bytes returns the total size of the keys and values held in memory,
+// including expired and soft-deleted ones not yet reclaimed. It does not
+// count the map's own overhead or the cold tier.
+func (kvs *KeyValueStore) Bytes() int64 {
+	kvs.mu.RLock()
+	defer kvs.mu.RUnlock()
+	return kvs.bytes
+}
+
+// DeleteExpired removes every key whose TTL and stale-while-revalidate window
+// have elapsed, and every soft-deleted key whose retention window has passed,
+// and returns how many were removed. Such keys are already invisible to Get;
+// this reclaims them. Recent-delete markers past their grace are dropped too.
+func (kvs *KeyValueStore) DeleteExpired() int {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	now := time.Now().UnixNano()
+	removed := 0
+	for key, e := range kvs.data {
+		if e.deleted != 0 && now-e.deleted < int64(kvs.softDelete) {
+			continue
+		}
+		if !e.live(now) && !kvs.staleLocked(e, now) {
+			if e.deleted == 0 {
+				kvs.evictedLocked(key, e, EvictExpired)
+			}
+			kvs.removeLocked(key)
+			removed++
+		}
+	}
+	kvs.forgetRecentDeletesLocked(now)
+	return removed
+}
@@ -0,0 +1,286 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStringAndBytesRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value []byte
+	}{
+		{"utf-8", []byte("héllo")},
+		{"non-utf-8", []byte{0xff, 0xfe, 0x00, 0x80, 'a'}},
+		{"nul bytes", []byte{0, 0, 0}},
+		{"empty", []byte{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kvs := NewKeyValueStore()
+			if err := kvs.PutBytes("k", tt.value); err != nil {
+				t.Fatal(err)
+			}
+			got, found := kvs.GetBytes("k")
+			if !found || !bytes.Equal(got, tt.value) {
+				t.Fatalf("GetBytes = %q, %v; want %q, true", got, found, tt.value)
+			}
+			if s, found := kvs.GetString("k"); !found || s != string(tt.value) {
+				t.Fatalf("GetString = %q, %v; want %q, true", s, found, tt.value)
+			}
+
+			if err := kvs.PutString("k", string(tt.value)); err != nil {
+				t.Fatal(err)
+			}
+			if got, found := kvs.GetBytes("k"); !found || !bytes.Equal(got, tt.value) {
+				t.Fatalf("GetBytes after PutString = %q, %v; want %q, true", got, found, tt.value)
+			}
+		})
+	}
+}
+
+func TestGetBytesReturnsCopy(t *testing.T) {
+	kvs := NewKeyValueStore()
+	kvs.PutBytes("k", []byte("abc"))
+	got, _ := kvs.GetBytes("k")
+	got[0] = 'x'
+	if v, _ := kvs.Get("k"); v != "abc" {
+		t.Fatalf("modifying GetBytes' result changed the stored value to %q", v)
+	}
+	if _, found := kvs.GetBytes("missing"); found {
+		t.Fatal("GetBytes found a missing key")
+	}
+}
+
+func TestMixedStringAndBytesWrites(t *testing.T) {
+	kvs := NewKeyValueStore()
+	kvs.PutString("k", "text")
+	kvs.PutBytes("k", []byte{0xc3, 0x28})
+	if got, _ := kvs.GetBytes("k"); !bytes.Equal(got, []byte{0xc3, 0x28}) {
+		t.Fatalf("GetBytes = %q after overwriting a string with bytes", got)
+	}
+	kvs.PutString("k", "text again")
+	if got, _ := kvs.GetString("k"); got != "text again" {
+		t.Fatalf("GetString = %q after overwriting bytes with a string", got)
+	}
+}
+
+// TestScanSnapshotUnderWrites runs scans while writers put and delete keys,
+// and checks every scan sees a single point in time. Each writer rewrites
+// its own keys in order with the round number as the value, so a snapshot
+// shows the keys before the writer's position one round ahead of the rest.
+// Run with -race.
+func TestScanSnapshotUnderWrites(t *testing.T) {
+	const (
+		writers = 4
+		keys    = 50
+		rounds  = 200
+	)
+	kvs := NewKeyValueStore()
+	key := func(w, i int) string { return fmt.Sprintf("stable/%d/%03d", w, i) }
+	for w := 0; w < writers; w++ {
+		for i := 0; i < keys; i++ {
+			kvs.Put(key(w, i), "0")
+		}
+	}
+
+	var done atomic.Bool
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for r := 1; r <= rounds; r++ {
+				for i := 0; i < keys; i++ {
+					kvs.Put(key(w, i), strconv.Itoa(r))
+				}
+			}
+		}(w)
+	}
+	// Churn keys outside the scanned prefix are created and deleted throughout.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; !done.Load(); i++ {
+			k := fmt.Sprintf("churn/%d", i%100)
+			kvs.Put(k, "x")
+			kvs.Delete(k)
+		}
+	}()
+
+	scans := 0
+	for {
+		finished := true
+		for w := 0; w < writers; w++ {
+			if v, _ := kvs.Get(key(w, keys-1)); v != strconv.Itoa(rounds) {
+				finished = false
+			}
+		}
+		seen := make(map[int][]int, writers) // writer -> round per key
+		for w := 0; w < writers; w++ {
+			seen[w] = make([]int, keys)
+			for i := range seen[w] {
+				seen[w][i] = -1
+			}
+		}
+		kvs.Scan("stable/", func(e Entry) bool {
+			var w, i int
+			if _, err := fmt.Sscanf(e.Key, "stable/%d/%d", &w, &i); err != nil {
+				t.Fatalf("scan returned %q outside the prefix", e.Key)
+			}
+			if seen[w][i] != -1 {
+				t.Fatalf("scan returned %q twice", e.Key)
+			}
+			seen[w][i], _ = strconv.Atoi(e.Value)
+			// Writing from the callback must neither block nor change the scan.
+			kvs.Put("scanned/"+e.Key, e.Value)
+			return true
+		})
+		for w := 0; w < writers; w++ {
+			first := seen[w][0]
+			for i, r := range seen[w] {
+				if r == -1 {
+					t.Fatalf("scan missed %q", key(w, i))
+				}
+				if r > seen[w][max(i-1, 0)] || r < first-1 {
+					t.Fatalf("scan %d of writer %d saw rounds %v, not a single point in time", scans, w, seen[w])
+				}
+			}
+		}
+		scans++
+		if finished {
+			break
+		}
+	}
+	done.Store(true)
+	wg.Wait()
+	if scans < 2 {
+		t.Logf("only %d scans overlapped the writers", scans)
+	}
+}
+
+func benchKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("user:%d:profile", i)
+	}
+	return keys
+}
+
+func BenchmarkPutOverwrite(b *testing.B) {
+	kvs := NewKeyValueStore()
+	keys := benchKeys(1024)
+	value := strings.Repeat("v", 4096)
+	for _, key := range keys {
+		kvs.Put(key, value)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := kvs.Put(keys[i%len(keys)], value); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPutNew(b *testing.B) {
+	kvs := NewKeyValueStoreWithCapacity(b.N)
+	keys := benchKeys(b.N)
+	value := strings.Repeat("v", 4096)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := kvs.Put(keys[i], value); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	kvs := NewKeyValueStore()
+	keys := benchKeys(1024)
+	for _, key := range keys {
+		kvs.Put(key, "value")
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		kvs.Get(keys[i%len(keys)])
+	}
+}
+
+// TestCompactUnderWrites compacts repeatedly while writers insert, overwrite
+// and delete keys, and checks nothing written is lost or resurrected.
+func TestCompactUnderWrites(t *testing.T) {
+	const (
+		writers = 4
+		keys    = 3000
+		old     = 50 * compactChunk
+	)
+	kvs := NewKeyValueStore()
+	// Churn up front guarantees the first Compact call compacts, and enough
+	// keys that each compaction releases the lock many times.
+	for i := 0; i < old; i++ {
+		kvs.Put(fmt.Sprintf("old/%d", i), "v")
+		kvs.Put(fmt.Sprintf("old/%d", i), "v")
+	}
+	var (
+		done atomic.Bool
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < keys; i++ {
+				key := fmt.Sprintf("%d/%d", w, i)
+				kvs.Put(key, "first")
+				kvs.Put(key, "second")
+				if i%3 == 0 {
+					kvs.Delete(key)
+				}
+			}
+		}(w)
+	}
+	compactions := 0
+	compacted := make(chan struct{})
+	go func() {
+		defer close(compacted)
+		for {
+			if kvs.Compact(0.1) {
+				compactions++
+			}
+			if done.Load() {
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	done.Store(true)
+	<-compacted
+
+	for w := 0; w < writers; w++ {
+		for i := 0; i < keys; i++ {
+			key := fmt.Sprintf("%d/%d", w, i)
+			value, found := kvs.Get(key)
+			if i%3 == 0 {
+				if found {
+					t.Fatalf("deleted key %s came back as %q", key, value)
+				}
+			} else if !found || value != "second" {
+				t.Fatalf("Get(%s) = %q, %v; want second, true", key, value, found)
+			}
+		}
+	}
+	if want := old + writers*(keys-keys/3); kvs.Len() != want {
+		t.Fatalf("Len = %d, want %d", kvs.Len(), want)
+	}
+	if compactions == 0 {
+		t.Fatal("Compact never compacted")
+	}
+}
@@ -0,0 +1,43 @@
+package store
+
+import "time"
+
+// SetDeleteGrace makes Delete remember the keys it removes for grace, so
+// RecentlyDeleted can tell a key that was deliberately deleted apart from one
+// that never existed. Only the key and the time are kept, not the value. Any
+// write to the key forgets it early. A zero grace disables it.
+func (kvs *KeyValueStore) SetDeleteGrace(grace time.Duration) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	kvs.deleteGrace = grace
+	if grace > 0 && kvs.recentDeletes == nil {
+		kvs.recentDeletes = make(map[string]int64)
+	}
+}
+
+// RecentlyDeleted reports whether key was removed by Delete within the
+// delete grace period and has not been written since.
+func (kvs *KeyValueStore) RecentlyDeleted(key string) bool {
+	kvs.mu.RLock()
+	defer kvs.mu.RUnlock()
+	deleted, ok := kvs.recentDeletes[key]
+	return ok && time.Now().UnixNano()-deleted < int64(kvs.deleteGrace)
+}
+
+// markDeletedLocked records that key was just deleted. The write lock must
+// be held.
+func (kvs *KeyValueStore) markDeletedLocked(key string) {
+	if kvs.deleteGrace > 0 {
+		kvs.recentDeletes[key] = time.Now().UnixNano()
+	}
+}
+
+// forgetRecentDeletesLocked drops the markers whose grace has passed. The
+// write lock must be held.
+func (kvs *KeyValueStore) forgetRecentDeletesLocked(now int64) {
+	for key, deleted := range kvs.recentDeletes {
+		if now-deleted >= int64(kvs.deleteGrace) {
+			delete(kvs.recentDeletes, key)
+		}
+	}
+}
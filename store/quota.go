@@ -0,0 +1,117 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrQuotaExceeded is returned when a write would add a key to a namespace
+// that already holds as many keys as its quota allows.
+var ErrQuotaExceeded = errors.New("namespace quota exceeded")
+
+// NamespaceUsage is how many keys a namespace holds against its quota.
+type NamespaceUsage struct {
+	Keys  int
+	Limit int
+}
+
+// QuotaReporter is implemented by engines that enforce namespace quotas.
+type QuotaReporter interface {
+	// NamespaceUsage returns the usage of every namespace with a quota,
+	// keyed by its prefix.
+	NamespaceUsage() map[string]NamespaceUsage
+}
+
+var _ QuotaReporter = (*KeyValueStore)(nil)
+
+// quota is the key count and limit of one namespace.
+type quota struct {
+	prefix string
+	limit  int
+	keys   int
+}
+
+// SetQuotas caps how many keys each namespace may hold, where a namespace
+// is a key prefix and a key belongs to the longest prefix it matches. A
+// write that would add a new key to a full namespace fails with
+// ErrQuotaExceeded; overwriting a key already held is always allowed. Keys
+// are counted while they are held in memory, so expired and soft-deleted
+// keys count until they are reclaimed, and keys only in the cold tier do not
+// count. A nil map removes every quota.
+func (kvs *KeyValueStore) SetQuotas(limits map[string]int) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	kvs.quotas = nil
+	for prefix, limit := range limits {
+		kvs.quotas = append(kvs.quotas, &quota{prefix: prefix, limit: limit})
+	}
+	// Longest prefix first, so the first match is the key's namespace.
+	sort.Slice(kvs.quotas, func(i, j int) bool { return len(kvs.quotas[i].prefix) > len(kvs.quotas[j].prefix) })
+	for key := range kvs.data {
+		if q := kvs.quotaLocked(key); q != nil {
+			q.keys++
+		}
+	}
+}
+
+// NamespaceUsage returns the key count and limit of every namespace with a
+// quota, keyed by its prefix.
+func (kvs *KeyValueStore) NamespaceUsage() map[string]NamespaceUsage {
+	kvs.mu.RLock()
+	defer kvs.mu.RUnlock()
+	usage := make(map[string]NamespaceUsage, len(kvs.quotas))
+	for _, q := range kvs.quotas {
+		usage[q.prefix] = NamespaceUsage{Keys: q.keys, Limit: q.limit}
+	}
+	return usage
+}
+
+// reservation is a namespace slot held for a key that a write will add once
+// it has released the lock to update the cold tier, so other writes cannot
+// fill the namespace in the meantime.
+type reservation struct{ q *quota }
+
+// reserveLocked reserves a slot for key in its namespace, unless it has no
+// quota or key is already held. The write lock must be held. The caller must
+// release the reservation, with the write lock held, before storing key.
+func (kvs *KeyValueStore) reserveLocked(key string) reservation {
+	if _, held := kvs.data[key]; held {
+		return reservation{}
+	}
+	q := kvs.quotaLocked(key)
+	if q != nil {
+		q.keys++
+	}
+	return reservation{q: q}
+}
+
+func (r reservation) release() {
+	if r.q != nil {
+		r.q.keys--
+	}
+}
+
+// quotaLocked returns the quota of the namespace key belongs to, or nil if
+// it has none. A lock must be held.
+func (kvs *KeyValueStore) quotaLocked(key string) *quota {
+	for _, q := range kvs.quotas {
+		if strings.HasPrefix(key, q.prefix) {
+			return q
+		}
+	}
+	return nil
+}
+
+// checkQuotaLocked fails if adding key would take its namespace over its
+// quota. The write lock must be held.
+func (kvs *KeyValueStore) checkQuotaLocked(key string) error {
+	if _, held := kvs.data[key]; held {
+		return nil
+	}
+	if q := kvs.quotaLocked(key); q != nil && q.keys >= q.limit {
+		return fmt.Errorf("%w: %q holds %d of %d keys", ErrQuotaExceeded, q.prefix, q.keys, q.limit)
+	}
+	return nil
+}
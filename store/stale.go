@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Loader fetches a fresh value for a key from wherever the store's data comes
+// from, such as the database a cache fronts. found is false if the key no
+// longer exists there. ctx is cancelled when the store is closed.
+type Loader func(ctx context.Context, key string) (value string, found bool, err error)
+
+// SetStaleWhileRevalidate keeps keys readable for window after their TTL
+// expires. A Get in that window returns the stale value at once and, if
+// loader is not nil, calls loader on a separate goroutine and stores what it
+// returns under the key's old TTL. At most one refresh per key runs at a
+// time, and a refresh is dropped if the key was written in the meantime or
+// loader fails. Writes never see stale values. Close cancels running
+// refreshes and waits for them to return. A zero window disables it.
+func (kvs *KeyValueStore) SetStaleWhileRevalidate(window time.Duration, loader Loader) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	kvs.staleWindow = window
+	kvs.loader = loader
+}
+
+// staleLocked reports whether e has expired but is still inside the
+// stale-while-revalidate window at now. A lock must be held.
+func (kvs *KeyValueStore) staleLocked(e *entry, now int64) bool {
+	return kvs.staleWindow > 0 && e.deleted == 0 && e.expiry != nil &&
+		e.expiry.expired(now) && now < e.expiry.deadline.Load()+int64(kvs.staleWindow)
+}
+
+// revalidate starts refreshing a stale key unless a refresh is already
+// running. A lock must be held.
+func (kvs *KeyValueStore) revalidate(key string, version uint64) {
+	if kvs.loader == nil {
+		return
+	}
+	kvs.refreshMu.Lock()
+	defer kvs.refreshMu.Unlock()
+	if kvs.refreshClosed || kvs.refreshing[key] {
+		return
+	}
+	if kvs.refreshing == nil {
+		kvs.refreshing = make(map[string]bool)
+	}
+	kvs.refreshing[key] = true
+	kvs.refreshes.Add(1)
+	go kvs.refresh(kvs.loader, key, version)
+}
+
+func (kvs *KeyValueStore) refresh(loader Loader, key string, version uint64) {
+	defer kvs.refreshes.Done()
+	defer func() {
+		kvs.refreshMu.Lock()
+		delete(kvs.refreshing, key)
+		kvs.refreshMu.Unlock()
+	}()
+	value, found, err := loader(kvs.refreshCtx, key)
+	if err != nil || !found || kvs.refreshCtx.Err() != nil {
+		return
+	}
+
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	e, ok := kvs.data[key]
+	if !ok || e.version != version || e.deleted != 0 || e.expiry == nil {
+		return
+	}
+	if kvs.maxValueSize > 0 && len(value) > kvs.maxValueSize {
+		return
+	}
+	kvs.seq++
+	stored := kvs.setLocked(key, entry{
+		value:       value,
+		version:     kvs.seq,
+		modified:    kvs.modifiedLocked(key),
+		expiry:      newExpiry(e.expiry.ttl, e.expiry.sliding),
+		contentType: e.contentType,
+	})
+	kvs.notifyLocked(ChangePut, key, stored)
+}
+
+// stopRefreshes cancels running refreshes, waits for them to return and
+// keeps new ones from starting. The store's lock must not be held, since
+// a refresh takes it to store its result.
+func (kvs *KeyValueStore) stopRefreshes() {
+	kvs.refreshMu.Lock()
+	kvs.refreshClosed = true
+	kvs.refreshMu.Unlock()
+	kvs.refreshStop()
+	kvs.refreshes.Wait()
+}
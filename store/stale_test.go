@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCloseWaitsForRefresh(t *testing.T) {
+	started := make(chan struct{})
+	var returned atomic.Bool
+	kvs := NewKeyValueStore()
+	kvs.SetStaleWhileRevalidate(time.Minute, func(ctx context.Context, key string) (string, bool, error) {
+		close(started)
+		<-ctx.Done()
+		returned.Store(true)
+		return "fresh", true, nil
+	})
+	kvs.PutWithTTL("k", "stale", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if value, found := kvs.Get("k"); !found || value != "stale" {
+		t.Fatalf("Get(k) = %q, %v; want the stale value", value, found)
+	}
+	<-started
+
+	if err := kvs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !returned.Load() {
+		t.Fatal("Close returned before the refresh did")
+	}
+	if value, _ := kvs.Get("k"); value == "fresh" {
+		t.Fatal("a refresh cancelled by Close still stored its value")
+	}
+}
+
+func TestNoRefreshAfterClose(t *testing.T) {
+	var calls atomic.Int32
+	kvs := NewKeyValueStore()
+	kvs.SetStaleWhileRevalidate(time.Minute, func(ctx context.Context, key string) (string, bool, error) {
+		calls.Add(1)
+		return "fresh", true, nil
+	})
+	kvs.PutWithTTL("k", "stale", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	kvs.Close()
+	kvs.Get("k")
+	time.Sleep(5 * time.Millisecond)
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("loader called %d times after Close", n)
+	}
+}
+
+func TestRefreshStoresFreshValue(t *testing.T) {
+	kvs := NewKeyValueStore()
+	defer kvs.Close()
+	refreshed := make(chan struct{})
+	kvs.SetStaleWhileRevalidate(time.Minute, func(ctx context.Context, key string) (string, bool, error) {
+		defer close(refreshed)
+		return "fresh", true, nil
+	})
+	kvs.PutWithTTL("k", "stale", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	kvs.Get("k")
+	<-refreshed
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if value, _ := kvs.Get("k"); value == "fresh" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("refreshed value was never stored")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
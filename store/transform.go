@@ -0,0 +1,50 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrValueRejected is returned when the write transform refuses a value.
+var ErrValueRejected = errors.New("value rejected")
+
+// WriteTransform is given every value written through Put and its variants
+// before it is stored, and returns the value to store in its place. Returning
+// an error rejects the write. It runs without the store's lock held, so it
+// may be slow, but it must be safe to call concurrently.
+type WriteTransform func(key, value string) (string, error)
+
+// SetWriteTransform installs a hook that normalizes or validates values on
+// write, such as trimming them or checking them against a schema. Values
+// restored from the cold tier or refreshed by the Loader are not passed
+// through it. Content-addressed keys (see ContentKey) must keep matching
+// their values, so the transform may reject those values but not rewrite
+// them. A nil transform, the default, removes it.
+func (kvs *KeyValueStore) SetWriteTransform(transform WriteTransform) {
+	kvs.mu.Lock()
+	defer kvs.mu.Unlock()
+	kvs.transform = transform
+}
+
+// transformValue runs the write transform, if any, over a value about to be
+// written. Errors are wrapped so they match ErrValueRejected.
+func (kvs *KeyValueStore) transformValue(key, value string) (string, error) {
+	kvs.mu.RLock()
+	transform := kvs.transform
+	kvs.mu.RUnlock()
+	if transform == nil {
+		return value, nil
+	}
+	out, err := transform(key, value)
+	if err != nil {
+		if !errors.Is(err, ErrValueRejected) {
+			err = fmt.Errorf("%w: %v", ErrValueRejected, err)
+		}
+		return "", err
+	}
+	if out != value && strings.HasPrefix(key, ContentKeyPrefix) {
+		return "", fmt.Errorf("%w: content-addressed values cannot be rewritten", ErrValueRejected)
+	}
+	return out, nil
+}